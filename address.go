@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineAddress limits replacements to some lines of a file, sed style: lines matching (or not matching)
+// a guard pattern, line number ranges, and blocks running from a start marker line to an end marker line.
+// A line has to satisfy every configured condition.
+type lineAddress struct {
+	matching    *regexp.Regexp
+	notMatching *regexp.Regexp
+	ranges      [][2]int // Inclusive line ranges, an end of 0 runs to the end of the file.
+	from, to    *regexp.Regexp
+}
+
+// newLineAddress builds the line address requested on the command line, or nil when every line is eligible.
+func newLineAddress(cfg *Config) (*lineAddress, error) {
+	if cfg.LinesMatching == "" && cfg.LinesNotMatching == "" && cfg.LineRanges == "" && cfg.FromMarker == "" && cfg.ToMarker == "" {
+		return nil, nil
+	}
+
+	address := &lineAddress{}
+	var err error
+	for _, guard := range []struct {
+		target  **regexp.Regexp
+		pattern string
+		flag    string
+	}{
+		{&address.matching, cfg.LinesMatching, "lines-matching"},
+		{&address.notMatching, cfg.LinesNotMatching, "lines-not-matching"},
+		{&address.from, cfg.FromMarker, "from-marker"},
+		{&address.to, cfg.ToMarker, "to-marker"},
+	} {
+		if guard.pattern == "" {
+			continue
+		}
+		if *guard.target, err = regexp.Compile(guard.pattern); err != nil {
+			return nil, fmt.Errorf("invalid -%s pattern %q: %w", guard.flag, guard.pattern, err)
+		}
+	}
+	if address.ranges, err = parseLineRanges(cfg.LineRanges); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// parseLineRanges reads a comma-separated list of line numbers and ranges such as "3,10-20,40-".
+func parseLineRanges(spec string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid line range %q", part)
+		}
+		end := start
+		if isRange {
+			end = 0
+			if last != "" {
+				if end, err = strconv.Atoi(last); err != nil || end < start {
+					return nil, fmt.Errorf("invalid line range %q", part)
+				}
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, nil
+}
+
+// allows reports whether line, the state.line-th line of the file, may be rewritten. It must be called
+// for every line in order, since it follows the start and end markers through the file.
+func (address *lineAddress) allows(line string, state *fileState) bool {
+	inBlock := address.inBlock(line, state)
+	if !inBlock {
+		return false
+	}
+	if address.matching != nil && !address.matching.MatchString(line) {
+		return false
+	}
+	if address.notMatching != nil && address.notMatching.MatchString(line) {
+		return false
+	}
+	if len(address.ranges) == 0 {
+		return true
+	}
+	for _, lineRange := range address.ranges {
+		if state.line >= lineRange[0] && (lineRange[1] == 0 || state.line <= lineRange[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// inBlock tracks the marker blocks. Marker lines belong to their block, and without a start marker
+// the block starts at the top of the file; without an end marker it runs to the bottom.
+func (address *lineAddress) inBlock(line string, state *fileState) bool {
+	if address.from == nil && address.to == nil {
+		return true
+	}
+	if !state.inBlock {
+		opens := address.from != nil && address.from.MatchString(line) || address.from == nil && !state.blockClosed
+		if !opens {
+			return false
+		}
+		state.inBlock = true
+		if address.from != nil {
+			return true // The end marker is only looked for on the lines after the start marker.
+		}
+	}
+	if address.to != nil && address.to.MatchString(line) {
+		state.inBlock, state.blockClosed = false, true
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// addressedShifter returns a shifter whose line address is built from cfg, failing the test on a bad address.
+func addressedShifter(t *testing.T, cfg Config) *NameShifter {
+	t.Helper()
+	cfg.CaseMatching = true
+	ns := NewNameShifter(&cfg, NewAppContext())
+	address, err := newLineAddress(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.address = address
+	return ns
+}
+
+const routes = `// "/old" routes
+route("/old")
+call("/old")
+// BEGIN generated
+route("/old")
+// END generated
+route("/old")
+`
+
+func TestLineAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want []int // Lines where "/old" is replaced.
+	}{
+		{"lines matching", Config{LinesMatching: `^route\(`}, []int{2, 5, 7}},
+		{"lines not matching", Config{LinesNotMatching: `^route\(`}, []int{1, 3}},
+		{"line ranges", Config{LineRanges: "1,3-5"}, []int{1, 3, 5}},
+		{"open range", Config{LineRanges: "6-"}, []int{7}},
+		{"marker block", Config{FromMarker: "BEGIN", ToMarker: "END"}, []int{5}},
+		{"from marker to the end", Config{FromMarker: "BEGIN"}, []int{5, 7}},
+		{"start to end marker", Config{ToMarker: "^call"}, []int{1, 2, 3}},
+		{"conditions combine", Config{LinesMatching: "route", LineRanges: "4-"}, []int{5, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := addressedShifter(t, tt.cfg)
+			got := shiftFile(t, ns, "routes.go", routes, ns.newRule("/old", "/new"))
+
+			var replaced []int
+			for i, line := range strings.Split(got, "\n") {
+				if strings.Contains(line, "/new") {
+					replaced = append(replaced, i+1)
+				}
+			}
+			if !reflect.DeepEqual(replaced, tt.want) {
+				t.Errorf("replaced on lines %v, want %v\n%s", replaced, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLineAddressInMultilineMode(t *testing.T) {
+	// Consecutive eligible lines form one block, so a pattern may span them but not leave them.
+	ns := addressedShifter(t, Config{Multiline: true, Regex: true, LineRanges: "2-3"})
+
+	got := shiftFile(t, ns, "a.md", "a\na\na\na\n", ns.newRule(`a\na`, "b"))
+	if want := "a\nb\na\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	got, err := parseLineRanges(" 3, 10-20 ,40-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]int{{3, 3}, {10, 20}, {40, 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, spec := range []string{"0", "x", "5-2", "-4", "3-y"} {
+		if _, err := parseLineRanges(spec); err == nil {
+			t.Errorf("parseLineRanges(%q) should fail", spec)
+		}
+	}
+}
+
+func TestNewLineAddress(t *testing.T) {
+	if address, err := newLineAddress(&Config{}); address != nil || err != nil {
+		t.Errorf("no address flags should mean no address, got %v, %v", address, err)
+	}
+	_, err := newLineAddress(&Config{FromMarker: "("})
+	if err == nil || !strings.Contains(err.Error(), "-from-marker") {
+		t.Errorf("got %v, want the invalid pattern blamed on -from-marker", err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// bytePatch is one replacement made in a file processed with -binary.
+type bytePatch struct {
+	path                  string
+	offset                int
+	original, replacement string
+	fitted                string // "padded" or "truncated" when -same-length changed the replacement's length.
+}
+
+// validateBinary checks the -binary, -same-length and -pad flags before anything is replaced. Binary files
+// have no lines, comments or syntax, so the options narrowing replacements down to those don't apply.
+func validateBinary(cfg *Config) error {
+	if !cfg.Binary {
+		if cfg.SameLength || cfg.Pad != "" {
+			return errors.New("-same-length and -pad need -binary")
+		}
+		return nil
+	}
+	if cfg.Pad != "" {
+		pad, err := unescape(cfg.Pad)
+		if err != nil {
+			return err
+		}
+		if len(pad) != 1 {
+			return fmt.Errorf("invalid -pad %q, expected a single byte such as \\0, \\x20 or ' '", cfg.Pad)
+		}
+		cfg.Pad = pad
+	}
+	if cfg.Scope != scopeAll || cfg.Data != dataNone || cfg.Markdown != markdownAll || cfg.Markup != markupAll || cfg.Columns != "" {
+		return errors.New("-binary can't be combined with -scope, -data, -markdown, -markup or -columns")
+	}
+	if cfg.LinesMatching != "" || cfg.LinesNotMatching != "" || cfg.LineRanges != "" || cfg.FromMarker != "" || cfg.ToMarker != "" {
+		return errors.New("-binary files have no lines to address")
+	}
+	if cfg.Normalize != "" || cfg.Fold {
+		return errors.New("-binary compares bytes, it can't be combined with -normalize or -fold")
+	}
+	return nil
+}
+
+// processBinaryFile applies the rules to the raw bytes of a file, which is read and written back whole,
+// so no byte outside the matches changes. Every replacement is recorded as a patch at its byte offset.
+// Rules run one after another, so a patch's offset is where the rule found its match; with -same-length
+// no patch moves anything, and offsets are those of the original file too.
+func (ns *NameShifter) processBinaryFile(path string, rules []*Rule) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	text := string(content)
+	state := newFileState(path)
+
+	passes := [][]*Rule{rules}
+	if !ns.Config.Simultaneous {
+		passes = passes[:0]
+		for _, rule := range rules {
+			passes = append(passes, []*Rule{rule})
+		}
+	}
+
+	var patches []bytePatch
+	for _, pass := range passes {
+		var edits []textEdit
+		if ns.Config.Simultaneous {
+			edits = ns.simultaneousEdits(text, pass, state)
+		} else {
+			edits = ns.ruleEdits(text, pass[0], state)
+		}
+		for i, edit := range edits {
+			patch := bytePatch{path: ns.displayPath(path), offset: edit.start, original: text[edit.start:edit.end]}
+			if ns.Config.SameLength {
+				edits[i].replacement, patch.fitted = ns.fitLength(edit.replacement, edit.end-edit.start)
+			}
+			patch.replacement = edits[i].replacement
+			patches = append(patches, patch)
+		}
+		text = applyEdits(text, edits)
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+
+	if err := ns.writeFileContent(path, []byte(text)); err != nil {
+		return err
+	}
+	ns.recordTally(state)
+	ns.Context.AddPatches(patches)
+	return nil
+}
+
+// fitLength pads replacement with the -pad byte, NUL by default, or truncates it to length bytes.
+func (ns *NameShifter) fitLength(replacement string, length int) (string, string) {
+	switch {
+	case len(replacement) > length:
+		return replacement[:length], "truncated"
+	case len(replacement) < length:
+		pad := byte(0)
+		if ns.Config.Pad != "" {
+			pad = ns.Config.Pad[0]
+		}
+		padded := []byte(replacement)
+		for len(padded) < length {
+			padded = append(padded, pad)
+		}
+		return string(padded), "padded"
+	}
+	return replacement, ""
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// patchFile writes content to a binary file, runs the rules over it with -binary and returns the
+// patched bytes.
+func patchFile(t *testing.T, ns *NameShifter, content []byte, rules ...*Rule) []byte {
+	t.Helper()
+	if err := validateBinary(ns.Config); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "firmware.bin")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ns.root = filepath.Dir(path)
+	if err := ns.processFile(path, rules); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patched
+}
+
+// binaryImage holds bytes that aren't valid UTF-8 around the strings the tests patch.
+var binaryImage = []byte("\x7fELF\xff\x00host=old.example\x00\xfe\xfdold\x00")
+
+func TestBinaryKeepsEveryOtherByte(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Binary: true}, NewAppContext())
+
+	got := patchFile(t, ns, binaryImage, ns.newRule("old", "newer"))
+	if want := []byte("\x7fELF\xff\x00host=newer.example\x00\xfe\xfdnewer\x00"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	want := []bytePatch{
+		{path: "firmware.bin", offset: 11, original: "old", replacement: "newer"},
+		{path: "firmware.bin", offset: 25, original: "old", replacement: "newer"},
+	}
+	if !reflect.DeepEqual(ns.Context.patches, want) {
+		t.Errorf("patches = %+v\nwant      %+v", ns.Context.patches, want)
+	}
+}
+
+func TestBinarySameLengthPads(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Binary: true, SameLength: true}, NewAppContext())
+
+	got := patchFile(t, ns, binaryImage, ns.newRule("old.example", "new.io"))
+	if want := []byte("\x7fELF\xff\x00host=new.io\x00\x00\x00\x00\x00\x00\xfe\xfdold\x00"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q", got)
+	}
+	if len(got) != len(binaryImage) {
+		t.Errorf("size changed from %d to %d", len(binaryImage), len(got))
+	}
+	if patch := ns.Context.patches[0]; patch.fitted != "padded" || patch.replacement != "new.io\x00\x00\x00\x00\x00" {
+		t.Errorf("patch = %+v", patch)
+	}
+}
+
+func TestBinarySameLengthTruncates(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Binary: true, SameLength: true, Pad: " "}, NewAppContext())
+
+	got := patchFile(t, ns, binaryImage, ns.newRule("old", "brand-new"), ns.newRule("host", "ip"))
+	if want := []byte("\x7fELF\xff\x00ip  =bra.example\x00\xfe\xfdbra\x00"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q", got)
+	}
+
+	// Nothing moves, so every offset is one in the original file, whichever rule made the patch.
+	var offsets []int
+	var fitted []string
+	for _, patch := range ns.Context.patches {
+		offsets = append(offsets, patch.offset)
+		fitted = append(fitted, patch.fitted)
+	}
+	if !reflect.DeepEqual(offsets, []int{11, 25, 6}) || !reflect.DeepEqual(fitted, []string{"truncated", "truncated", "padded"}) {
+		t.Errorf("offsets = %v, fitted = %q", offsets, fitted)
+	}
+}
+
+func TestBinaryOffsetsFollowEarlierRules(t *testing.T) {
+	// Without -same-length the second rule sees the first one's output, and its offsets are in that.
+	ns := NewNameShifter(&Config{CaseMatching: true, Binary: true}, NewAppContext())
+
+	patchFile(t, ns, []byte("aa\x00b"), ns.newRule("aa", "aaaa"), ns.newRule("b", "c"))
+	if got := ns.Context.patches[1]; got.offset != 5 || got.original != "b" {
+		t.Errorf("second patch = %+v, want offset 5", got)
+	}
+}
+
+func TestBinaryWithoutMatchIsUntouched(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Binary: true}, NewAppContext())
+
+	if got := patchFile(t, ns, binaryImage, ns.newRule("missing", "x")); !reflect.DeepEqual(got, binaryImage) {
+		t.Errorf("got %q", got)
+	}
+	if len(ns.Context.patches) != 0 {
+		t.Errorf("patches = %+v", ns.Context.patches)
+	}
+}
+
+func TestValidateBinary(t *testing.T) {
+	tests := []*Config{
+		{SameLength: true},
+		{Pad: " "},
+		{Binary: true, SameLength: true, Pad: "ab"},
+		{Binary: true, Scope: scopeStrings},
+		{Binary: true, LineRanges: "1-2"},
+		{Binary: true, Fold: true},
+	}
+	for _, cfg := range tests {
+		if err := validateBinary(cfg); err == nil {
+			t.Errorf("accepted %+v", *cfg)
+		}
+	}
+
+	cfg := &Config{Binary: true, SameLength: true, Pad: `\xff`}
+	if err := validateBinary(cfg); err != nil || cfg.Pad != "\xff" {
+		t.Errorf("-pad=\\xff: %v, unescaped to %q", err, cfg.Pad)
+	}
+}
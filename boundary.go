@@ -0,0 +1,71 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// boundaryMode controls which edges a match must sit on to count.
+type boundaryMode int
+
+const (
+	boundaryNone boundaryMode = iota
+	// boundaryWord requires the match to be a whole word: no letter, digit or underscore right before or after it.
+	boundaryWord
+	// boundaryIdentifier also accepts underscores and camelCase humps as edges, so "id" matches in user_id and userId but not in width.
+	boundaryIdentifier
+)
+
+// isWordRune reports whether r belongs to a word for whole-word matching.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// atBoundaries reports whether text[start:end] sits on the edges required by mode.
+func atBoundaries(text string, start, end int, mode boundaryMode) bool {
+	if mode == boundaryNone || start == end {
+		return true
+	}
+	return boundaryBefore(text, start, mode) && boundaryAfter(text, end, mode)
+}
+
+// boundaryBefore checks the edge between text[:start] and text[start:].
+func boundaryBefore(text string, start int, mode boundaryMode) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	prev, size := utf8.DecodeLastRuneInString(text[:start])
+	if size == 0 || !isWordRune(first) || !isWordRune(prev) {
+		return true
+	}
+	if mode != boundaryIdentifier {
+		return false
+	}
+	if prev == '_' || first == '_' {
+		return true
+	}
+	// A hump starts here when an upper case letter follows a lower case letter or digit.
+	return unicode.IsUpper(first) && (unicode.IsLower(prev) || unicode.IsDigit(prev))
+}
+
+// boundaryAfter checks the edge between text[:end] and text[end:].
+func boundaryAfter(text string, end int, mode boundaryMode) bool {
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	next, size := utf8.DecodeRuneInString(text[end:])
+	if size == 0 || !isWordRune(last) || !isWordRune(next) {
+		return true
+	}
+	if mode != boundaryIdentifier {
+		return false
+	}
+	if last == '_' || next == '_' {
+		return true
+	}
+	if !unicode.IsUpper(next) {
+		return false
+	}
+	if unicode.IsLower(last) || unicode.IsDigit(last) {
+		return true
+	}
+	// After an acronym such as "ID" in "IDCard", the next hump starts at an upper case letter followed by a lower case one.
+	afterNext, _ := utf8.DecodeRuneInString(text[end+size:])
+	return unicode.IsUpper(last) && unicode.IsLower(afterNext)
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+const boundaryText = "width valid id user_id userId IDCard grid_ID"
+
+func TestWholeWordMatching(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, WholeWord: true}, NewAppContext())
+
+	got := replaceAll(ns, boundaryText, "id", "key")
+	if want := "width valid key user_id userId IDCard grid_ID"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestIdentifierWordMatching(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, IdentWord: true}, NewAppContext())
+
+	got := replaceAll(ns, boundaryText, "id", "key")
+	if want := "width valid key user_key userkey keyCard grid_key"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestWholeWordRetriesOverlappingOccurrences(t *testing.T) {
+	// The first "aa" inside "aaa" is rejected, the search resumes one character later rather than after it.
+	ns := NewNameShifter(&Config{CaseMatching: true, WholeWord: true}, NewAppContext())
+
+	if got := replaceAll(ns, "aaa aa", "aa", "bb"); got != "aaa bb" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWholeWordAppliesToRegexMatches(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, WholeWord: true}, NewAppContext())
+
+	if got := replaceAll(ns, "in id inside hidden", `i[dn]`, "X"); got != "X X inside hidden" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAtBoundaries(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		mode       boundaryMode
+		want       bool
+	}{
+		{"width", 1, 3, boundaryNone, true},
+		{"width", 1, 3, boundaryWord, false},
+		{"user_id", 5, 7, boundaryWord, false},
+		{"user_id", 5, 7, boundaryIdentifier, true},
+		{"userId", 4, 6, boundaryIdentifier, true},
+		{"IDCard", 0, 2, boundaryIdentifier, true},
+		{"IDS", 0, 2, boundaryIdentifier, false},
+		{"(id)", 1, 3, boundaryWord, true},
+		{"héid", 3, 5, boundaryWord, false}, // the rune before the match is a letter, not a boundary.
+	}
+	for _, tt := range tests {
+		if got := atBoundaries(tt.text, tt.start, tt.end, tt.mode); got != tt.want {
+			t.Errorf("atBoundaries(%q, %d, %d, %d) = %v, want %v", tt.text, tt.start, tt.end, tt.mode, got, tt.want)
+		}
+	}
+}
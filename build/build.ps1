@@ -21,23 +21,23 @@ function Build-GoApplication {
     $env:GOOS="windows"
     $env:GOARCH="amd64"
     $env:CGO_ENABLED="0"
-    go build -ldflags "-s -w" -o $pwd\target\win32\nsh.exe main.go helpers.go
+    go build -ldflags "-s -w" -o $pwd\target\win32\nsh.exe .
 
     # Linux build
     $env:GOOS="linux"
-    go build -ldflags "-s -w" -o $pwd\target\linux\nsh main.go helpers.go
+    go build -ldflags "-s -w" -o $pwd\target\linux\nsh .
 
     # macOS build
     $env:GOOS="darwin"
     $env:GOARCH="arm64"
-    go build -ldflags "-s -w" -o $pwd\target\darwin\nsh main.go helpers.go
+    go build -ldflags "-s -w" -o $pwd\target\darwin\nsh .
 
     # Reset environment variables for resource generation and final Windows build
     Remove-Item Env:\GOOS
     Remove-Item Env:\GOARCH
     $env:CGO_ENABLED="0"
     rsrc -ico assets\nsh.ico -o assets\nsh.syso
-    go build -ldflags "-s -w -r" -o $pwd\target\win32\nsh.exe main.go helpers.go
+    go build -ldflags "-s -w -r" -o $pwd\target\win32\nsh.exe .
 
     # Restore previous working directory
     Set-Location $pwd
@@ -20,8 +20,9 @@ if [ -z "$main_path" ] || [ -z "$helpers_path" ]; then
     echo "Error: Required files (main.go, helpers.go) not found."
     exit 1
 else
-    # If both files are found, proceed with build for each target
-    GOOS="darwin" GOARCH="arm64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/darwin/nsh $main_path $helpers_path
-    GOOS="linux" GOARCH="amd64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/linux/nsh $main_path $helpers_path
-    GOOS="windows" GOARCH="amd64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/win32/nsh.exe $main_path $helpers_path
+    # If both files are found, build the package they're in for each target
+    package_dir=$(dirname "$main_path")
+    GOOS="darwin" GOARCH="arm64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/darwin/nsh "$package_dir"
+    GOOS="linux" GOARCH="amd64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/linux/nsh "$package_dir"
+    GOOS="windows" GOARCH="amd64" CGO_ENABLED="0" go build -ldflags "-s -w" -o target/win32/nsh.exe "$package_dir"
 fi
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseShape describes how a piece of text is cased.
+type caseShape int
+
+const (
+	shapeOther caseShape = iota // No letters, or a mix that doesn't fit the shapes below.
+	shapeLower
+	shapeUpper
+	shapeTitle
+)
+
+// shapeOf classifies s as lower case, UPPER case, Title case or something else.
+func shapeOf(s string) caseShape {
+	var hasLower, hasUpper, firstUpper, restUpper bool
+	first := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+			if first {
+				firstUpper = true
+			} else {
+				restUpper = true
+			}
+		case unicode.IsLower(r):
+			hasLower = true
+		}
+		first = false
+	}
+
+	switch {
+	case hasLower && !hasUpper:
+		return shapeLower
+	case hasUpper && !hasLower:
+		return shapeUpper
+	case firstUpper && !restUpper:
+		return shapeTitle
+	}
+	return shapeOther
+}
+
+// applyShape recases s to the given shape, leaving it untouched for shapeOther.
+func applyShape(s string, shape caseShape) string {
+	switch shape {
+	case shapeLower:
+		return strings.ToLower(s)
+	case shapeUpper:
+		return strings.ToUpper(s)
+	case shapeTitle:
+		r, size := utf8.DecodeRuneInString(s)
+		if size == 0 {
+			return s
+		}
+		return string(unicode.ToUpper(r)) + strings.ToLower(s[size:])
+	}
+	return s
+}
+
+// wordSpans splits an identifier or phrase into its words and returns their byte offsets.
+// Separators (anything that isn't a letter or digit) end a word, and so do camelCase humps,
+// with acronyms kept together: "HTTPServer_id" yields "HTTP", "Server" and "id".
+func wordSpans(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	var prev rune
+	for i, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			next, _ := utf8.DecodeRuneInString(s[i+utf8.RuneLen(r):])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && unicode.IsLower(next)) {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+		prev = r
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(s)})
+	}
+	return spans
+}
+
+// recaseWords recases every word of s to shape, keeping whatever separates the words.
+func recaseWords(s string, shape caseShape) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range wordSpans(s) {
+		b.WriteString(s[last:span[0]])
+		b.WriteString(applyShape(s[span[0]:span[1]], shape))
+		last = span[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// matchCase recases replacement so it follows the casing of match. A match spelled exactly like
+// the search string keeps the replacement as typed; otherwise lower, UPPER and Title matches are
+// mapped as a whole, and mixed matches such as "fooBar" are mapped word by word, with any extra
+// replacement words following the shape of the last matched word.
+func matchCase(match, search, replacement string) string {
+	if match == search || replacement == "" {
+		return replacement
+	}
+
+	switch shapeOf(match) {
+	case shapeLower:
+		return strings.ToLower(replacement)
+	case shapeUpper:
+		return strings.ToUpper(replacement)
+	case shapeTitle:
+		if len(wordSpans(match)) == 1 {
+			r, size := utf8.DecodeRuneInString(replacement)
+			return string(unicode.ToUpper(r)) + replacement[size:]
+		}
+	}
+
+	matchWords := wordSpans(match)
+	if len(matchWords) == 0 {
+		return replacement
+	}
+	shapes := make([]caseShape, len(matchWords))
+	for i, span := range matchWords {
+		shapes[i] = shapeOf(match[span[0]:span[1]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, span := range wordSpans(replacement) {
+		shape := shapes[len(shapes)-1]
+		if i < len(shapes) {
+			shape = shapes[i]
+		}
+		b.WriteString(replacement[last:span[0]])
+		b.WriteString(applyShape(replacement[span[0]:span[1]], shape))
+		last = span[1]
+	}
+	b.WriteString(replacement[last:])
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordSpans(t *testing.T) {
+	words := func(s string) []string {
+		var out []string
+		for _, span := range wordSpans(s) {
+			out = append(out, s[span[0]:span[1]])
+		}
+		return out
+	}
+
+	tests := map[string][]string{
+		"HTTPServer_id":  {"HTTP", "Server", "id"},
+		"fooBar":         {"foo", "Bar"},
+		"order-item v2":  {"order", "item", "v2"},
+		"parseJSON2Yaml": {"parse", "JSON2", "Yaml"},
+		"__":             nil,
+	}
+	for input, want := range tests {
+		if got := words(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("wordSpans(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestMatchCase(t *testing.T) {
+	tests := []struct {
+		match, replacement, want string
+	}{
+		{"orderitem", "lineItem", "lineitem"},    // lower case match.
+		{"ORDERITEM", "lineItem", "LINEITEM"},    // UPPER case match.
+		{"Orderitem", "lineItem", "LineItem"},    // a single Title word keeps the replacement's humps.
+		{"orderItem", "lineItem", "lineItem"},    // mixed, mapped word by word.
+		{"OrderItem", "lineItem", "LineItem"},    // Title words, mapped word by word.
+		{"ORDER_item", "line_item", "LINE_item"}, // separators come from the replacement.
+	}
+	for _, tt := range tests {
+		if got := matchCase(tt.match, "order-item", tt.replacement); got != tt.want {
+			t.Errorf("matchCase(%q, %q) = %q, want %q", tt.match, tt.replacement, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCaseKeepsReplacementForExactMatch(t *testing.T) {
+	if got := matchCase("fooBar", "fooBar", "bazQUX"); got != "bazQUX" {
+		t.Errorf("matchCase = %q, want the replacement as typed", got)
+	}
+}
+
+func TestMatchCaseExtraWordsFollowLastWord(t *testing.T) {
+	// "fooBar" has two words, "baz qux quux" three: the third follows the shape of "Bar".
+	if got := matchCase("fooBar", "foobar", "baz qux quux"); got != "baz Qux Quux" {
+		t.Errorf("matchCase = %q", got)
+	}
+}
+
+func TestReplaceStringPreservesCase(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, PreserveCase: true}, NewAppContext())
+
+	got := replaceAll(ns, "acme Acme ACME aCME", "acme", "globex")
+	if want := "globex Globex GLOBEX globex"; got != want {
+		t.Errorf("replaceString = %q, want %q", got, want)
+	}
+}
+
+func TestReplaceStringPreservesCaseOfExpandedRegex(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, PreserveCase: true, Regex: true}, NewAppContext())
+
+	got := replaceAll(ns, "Old_v1 OLD_V2", `old_v(\d)`, "new_r$1")
+	if want := "New_r1 NEW_R2"; got != want {
+		t.Errorf("replaceString = %q, want %q", got, want)
+	}
+}
+
+func TestMatchCaseKeepsEmptyReplacementEmpty(t *testing.T) {
+	// Deleting a Title match mustn't leave the replacement character behind.
+	ns := NewNameShifter(&Config{CaseMatching: false, PreserveCase: true}, NewAppContext())
+
+	if got := replaceAll(ns, "Acme acme ACME.", "acme", ""); got != "  ." {
+		t.Errorf("replaceString = %q, want %q", got, "  .")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// validateColumns checks the -columns and -header flags before anything is replaced.
+func validateColumns(cfg *Config) error {
+	if cfg.Columns == "" {
+		return nil
+	}
+	for _, column := range strings.Split(cfg.Columns, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return fmt.Errorf("invalid -columns %q, a column is empty", cfg.Columns)
+		}
+		if index, err := strconv.Atoi(column); err == nil && index < 1 {
+			return fmt.Errorf("invalid -columns %q, column numbers start at 1", cfg.Columns)
+		} else if err != nil && !cfg.Header {
+			return fmt.Errorf("-columns %q names a column, but -header=false says there's no header row to find it in", column)
+		}
+	}
+	if cfg.Scope != scopeAll || cfg.Data != dataNone || cfg.Markdown != markdownAll || cfg.Markup != markupAll {
+		return errors.New("-columns can't be combined with -scope, -data, -markdown or -markup")
+	}
+	return nil
+}
+
+// isTableFile reports whether path is a CSV or TSV file -columns can take apart.
+func isTableFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// selectedColumns returns the 0-based indexes of the columns -columns selects in a file with the given
+// header, which is nil without one. A column is found by header name first, then by 1-based number;
+// names a file's header lacks select nothing in it.
+func (ns *NameShifter) selectedColumns(header []string) map[int]bool {
+	selected := make(map[int]bool)
+	for _, column := range strings.Split(ns.Config.Columns, ",") {
+		column = strings.TrimSpace(column)
+		found := false
+		for i, name := range header {
+			if strings.TrimSpace(name) == column {
+				selected[i], found = true, true
+			}
+		}
+		if index, err := strconv.Atoi(column); err == nil && !found {
+			selected[index-1] = true
+		}
+	}
+	return selected
+}
+
+// rewriteColumns applies the rules to the fields of the columns -columns selects, one field at a time.
+// Fields are read with encoding/csv, so quoted delimiters, quotes and line breaks are part of the field
+// they belong to; a replaced field is quoted when it needs to be, everything else is written back as read.
+func (ns *NameShifter) rewriteColumns(content string, state *fileState, rules []*Rule) (string, error) {
+	body := strings.TrimPrefix(content, byteOrderMark)
+	offset := len(content) - len(body)
+
+	reader := csv.NewReader(strings.NewReader(body))
+	if strings.ToLower(filepath.Ext(state.path)) == ".tsv" {
+		reader.Comma = '\t'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	lineStarts := []int{0}
+	for i := 0; i < len(body); i++ {
+		if body[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	var nodes []dataNode
+	var selected map[int]bool
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if row == 0 {
+			if ns.Config.Header {
+				selected = ns.selectedColumns(record)
+				continue // The header names columns, it isn't data.
+			}
+			selected = ns.selectedColumns(nil)
+		}
+
+		for i, field := range record {
+			if !selected[i] {
+				continue
+			}
+			line, column := reader.FieldPos(i)
+			start := lineStarts[line-1] + column - 1
+			end := fieldEnd(body, start, reader.Comma)
+			nodes = append(nodes, dataNode{
+				span:  span{offset + start, offset + end},
+				value: field,
+				quote: quoteField(body[start:end], reader.Comma),
+			})
+		}
+	}
+	return ns.rewriteNodes(content, nodes, rules, state), nil
+}
+
+// fieldEnd returns the offset just past the raw field starting at start.
+func fieldEnd(body string, start int, comma rune) int {
+	if start < len(body) && body[start] == '"' {
+		for i := start + 1; i < len(body); i++ {
+			if body[i] != '"' {
+				continue
+			}
+			if i+1 < len(body) && body[i+1] == '"' {
+				i++ // An escaped quote.
+				continue
+			}
+			return i + 1
+		}
+		return len(body)
+	}
+	end := strings.IndexAny(body[start:], string(comma)+"\r\n")
+	if end < 0 {
+		return len(body)
+	}
+	return start + end
+}
+
+// quoteField returns the function spelling a replaced field back in the style of raw. Quoted fields stay
+// quoted, with their CRLF line breaks if they had any; unquoted ones get quotes only if they now need them.
+func quoteField(raw string, comma rune) func(string) string {
+	quoted := strings.HasPrefix(raw, `"`)
+	crlf := strings.Contains(raw, "\r\n")
+	return func(replaced string) string {
+		if crlf {
+			replaced = strings.ReplaceAll(replaced, "\n", "\r\n")
+		}
+		if !quoted && !strings.ContainsAny(replaced, string(comma)+"\"\r\n") {
+			return replaced
+		}
+		return `"` + strings.ReplaceAll(replaced, `"`, `""`) + `"`
+	}
+}
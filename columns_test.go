@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+// shiftColumns runs old → new over a table file with the given -columns and -header settings.
+func shiftColumns(t *testing.T, name, columns string, header bool, content string) string {
+	t.Helper()
+	cfg := &Config{CaseMatching: true, Columns: columns, Header: header}
+	if err := validateColumns(cfg); err != nil {
+		t.Fatal(err)
+	}
+	ns := NewNameShifter(cfg, NewAppContext())
+	return shiftFile(t, ns, name, content, ns.newRule("old", "new"))
+}
+
+func TestColumnsByHeaderName(t *testing.T) {
+	got := shiftColumns(t, "a.csv", "status", true, "name,status,old\nold,old,old\nbold,cold,old\n")
+	if want := "name,status,old\nold,new,old\nbold,cnew,old\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsByNumber(t *testing.T) {
+	// Without a header the first row is data too, and column numbers start at 1.
+	got := shiftColumns(t, "a.csv", "1, 3", false, "old,old,old\nold,old\n")
+	if want := "new,old,new\nnew,old\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsHeaderNamesWinOverNumbers(t *testing.T) {
+	// The header names a column "2", so it's that one rather than the second.
+	got := shiftColumns(t, "a.csv", "2", true, "a,b,2\nold,old,old\n")
+	if want := "a,b,2\nold,old,new\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsQuotedFields(t *testing.T) {
+	content := "id,note\n1,\"old, \"\"older\"\"\nline\"\n2,old\n3,\"old\"\n"
+
+	got := shiftColumns(t, "a.csv", "note", true, content)
+	if want := "id,note\n1,\"new, \"\"newer\"\"\nline\"\n2,new\n3,\"new\"\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsQuoteReplacementsThatNeedIt(t *testing.T) {
+	cfg := &Config{CaseMatching: true, Columns: "2", Header: false}
+	ns := NewNameShifter(cfg, NewAppContext())
+
+	got := shiftFile(t, ns, "a.csv", "x,old\r\ny,\"old\r\nold\"\r\n", ns.newRule("old", "a,\"b\"\nc"))
+	want := "x,\"a,\"\"b\"\"\nc\"\r\ny,\"a,\"\"b\"\"\r\nc\r\na,\"\"b\"\"\r\nc\"\r\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsTSV(t *testing.T) {
+	got := shiftColumns(t, "a.tsv", "path", true, byteOrderMark+"name\tpath\nold, one\t/old/x\n")
+	if want := byteOrderMark + "name\tpath\nold, one\t/new/x\n"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestColumnsLeaveOtherFilesAlone(t *testing.T) {
+	if got := shiftColumns(t, "a.txt", "1", true, "old,old\nold,old\n"); got != "old,old\nold,old\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	tests := []*Config{
+		{Columns: "a,,b", Header: true},
+		{Columns: "0", Header: true},
+		{Columns: "name", Header: false},
+		{Columns: "1", Header: true, Markup: markupText},
+	}
+	for _, cfg := range tests {
+		if err := validateColumns(cfg); err == nil {
+			t.Errorf("accepted -columns=%q with %+v", cfg.Columns, *cfg)
+		}
+	}
+}
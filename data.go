@@ -0,0 +1,794 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parts of structured data files -data narrows replacements down to.
+const (
+	dataNone   = ""
+	dataKeys   = "keys"
+	dataValues = "values"
+)
+
+// byteOrderMark is the UTF-8 byte order mark some editors start files with.
+const byteOrderMark = "\ufeff"
+
+// dataNode is a key or scalar value of a JSON, YAML or TOML file.
+type dataNode struct {
+	span  span     // Raw bytes of the key or value, quotes included.
+	key   bool     // Whether it's a key rather than a value.
+	path  []string // Keys and array indexes leading to the node, a key's own name included.
+	value string   // Decoded text the rules are applied to.
+	// quote spells replaced text back in the file's syntax, adding quotes or escapes where it needs them.
+	quote func(string) string
+}
+
+// validateData checks the -data and -data-path flags before anything is replaced.
+func validateData(cfg *Config) error {
+	if cfg.DataPath != "" && cfg.Data == dataNone {
+		cfg.Data = dataValues
+	}
+	switch cfg.Data {
+	case dataNone, dataKeys, dataValues:
+	default:
+		return fmt.Errorf("invalid -data %q, expected \"keys\" or \"values\"", cfg.Data)
+	}
+	if cfg.Data != dataNone && cfg.Scope != scopeAll {
+		return errors.New("-data and -scope can't be combined")
+	}
+	return nil
+}
+
+// isDataFile reports whether path is a JSON, YAML or TOML file -data can take apart.
+func isDataFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// dataNodes finds the keys and scalar values of a structured data file, in file order.
+func dataNodes(path, content string) ([]dataNode, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return jsonNodes(content)
+	case ".yaml", ".yml":
+		return yamlNodes(content)
+	case ".toml":
+		return tomlNodes(content)
+	}
+	return nil, fmt.Errorf("%s isn't a JSON, YAML or TOML file", path)
+}
+
+// selects reports whether -data and -data-path make node eligible for replacement.
+func (ns *NameShifter) selects(node dataNode) bool {
+	if node.key != (ns.Config.Data == dataKeys) {
+		return false
+	}
+	if ns.Config.DataPath == "" {
+		return true
+	}
+	return matchDataPath(strings.Split(ns.Config.DataPath, "."), node.path)
+}
+
+// matchDataPath matches a node path against a path expression, where "*" stands for any one key or
+// array index and "**" for any number of them, e.g. services.*.image or **.image.
+func matchDataPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchDataPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (pattern[0] != "*" && pattern[0] != path[0]) {
+		return false
+	}
+	return matchDataPath(pattern[1:], path[1:])
+}
+
+// rewriteData applies the rules to the keys or values -data selects, one at a time. Everything else,
+// comments and formatting included, is written back exactly as read.
+func (ns *NameShifter) rewriteData(content string, state *fileState, rules []*Rule) (string, error) {
+	nodes, err := dataNodes(state.path, content)
+	if err != nil {
+		return "", err
+	}
+	selected := nodes[:0]
+	for _, node := range nodes {
+		if ns.selects(node) {
+			selected = append(selected, node)
+		}
+	}
+	return ns.rewriteNodes(content, selected, rules, state), nil
+}
+
+// rewriteNodes applies the rules to the value of every node on its own, in file order, and writes the
+// replaced ones back spelled by their quote function. Nodes outside the line address are skipped.
+func (ns *NameShifter) rewriteNodes(content string, nodes []dataNode, rules []*Rule, state *fileState) string {
+	allowed := ns.addressSpans(content, state)
+
+	var b strings.Builder
+	last, line, counted := 0, 1, 0
+	for _, node := range nodes {
+		if node.span.start < last || !spansContain(allowed, node.span.start) {
+			continue
+		}
+		line += strings.Count(content[counted:node.span.start], "\n")
+		counted = node.span.start
+		state.line = line
+
+		replaced := ns.applyRules(node.value, rules, state)
+		if replaced == node.value {
+			continue
+		}
+		b.WriteString(content[last:node.span.start])
+		b.WriteString(node.quote(replaced))
+		last = node.span.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// spansContain reports whether offset lies within one of the sorted spans.
+func spansContain(spans []span, offset int) bool {
+	for _, s := range spans {
+		if offset < s.start {
+			return false
+		}
+		if offset < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteBasic spells s as a double-quoted string with backslash escapes, which JSON, YAML and TOML all read.
+func quoteBasic(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// unchanged spells replaced text exactly as it is, for raw spans such as block scalar lines.
+func unchanged(s string) string {
+	return s
+}
+
+// jsonParser finds the keys and values of a JSON document with a small recursive descent parser,
+// since encoding/json doesn't tell where things are.
+type jsonParser struct {
+	content string
+	pos     int
+	nodes   []dataNode
+}
+
+func jsonNodes(content string) ([]dataNode, error) {
+	p := &jsonParser{content: content}
+	if strings.HasPrefix(content, byteOrderMark) {
+		p.pos = len(byteOrderMark)
+	}
+	p.skipSpace()
+	if err := p.value(nil); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(content) {
+		return nil, p.errorf("unexpected %q after the top level value", content[p.pos])
+	}
+	return p.nodes, nil
+}
+
+func (p *jsonParser) errorf(format string, args ...any) error {
+	line := 1 + strings.Count(p.content[:p.pos], "\n")
+	return fmt.Errorf("invalid JSON on line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.content) && strings.IndexByte(" \t\r\n", p.content[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonParser) value(path []string) error {
+	if p.pos >= len(p.content) {
+		return p.errorf("unexpected end of input")
+	}
+	switch p.content[p.pos] {
+	case '{':
+		return p.object(path)
+	case '[':
+		return p.array(path)
+	case '"':
+		value, s, err := p.string()
+		if err != nil {
+			return err
+		}
+		p.nodes = append(p.nodes, dataNode{span: s, path: path, value: value, quote: quoteBasic})
+		return nil
+	}
+	start := p.pos
+	for p.pos < len(p.content) && strings.IndexByte(" \t\r\n,]}", p.content[p.pos]) < 0 {
+		p.pos++
+	}
+	if start == p.pos {
+		return p.errorf("unexpected %q", p.content[p.pos])
+	}
+	raw := p.content[start:p.pos]
+	p.nodes = append(p.nodes, dataNode{span: span{start, p.pos}, path: path, value: raw, quote: quoteJSONLiteral})
+	return nil
+}
+
+// quoteJSONLiteral keeps numbers, booleans and null bare, and turns anything else into a string.
+func quoteJSONLiteral(s string) string {
+	if s == "true" || s == "false" || s == "null" {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil && strings.IndexByte("-0123456789", s[0]) >= 0 && !strings.ContainsAny(s, "xXpP_") {
+		return s
+	}
+	return quoteBasic(s)
+}
+
+func (p *jsonParser) object(path []string) error {
+	p.pos++ // {
+	p.skipSpace()
+	if p.pos < len(p.content) && p.content[p.pos] == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.content) || p.content[p.pos] != '"' {
+			return p.errorf("expected a key")
+		}
+		key, s, err := p.string()
+		if err != nil {
+			return err
+		}
+		keyPath := append(append([]string{}, path...), key)
+		p.nodes = append(p.nodes, dataNode{span: s, key: true, path: keyPath, value: key, quote: quoteBasic})
+
+		p.skipSpace()
+		if p.pos >= len(p.content) || p.content[p.pos] != ':' {
+			return p.errorf("expected ':' after key %q", key)
+		}
+		p.pos++
+		p.skipSpace()
+		if err := p.value(keyPath); err != nil {
+			return err
+		}
+		if done, err := p.next('}'); done || err != nil {
+			return err
+		}
+	}
+}
+
+func (p *jsonParser) array(path []string) error {
+	p.pos++ // [
+	p.skipSpace()
+	if p.pos < len(p.content) && p.content[p.pos] == ']' {
+		p.pos++
+		return nil
+	}
+	for index := 0; ; index++ {
+		p.skipSpace()
+		if err := p.value(append(append([]string{}, path...), strconv.Itoa(index))); err != nil {
+			return err
+		}
+		if done, err := p.next(']'); done || err != nil {
+			return err
+		}
+	}
+}
+
+// next consumes the comma before the next member, or the closing bracket, reporting which it was.
+func (p *jsonParser) next(closing byte) (bool, error) {
+	p.skipSpace()
+	if p.pos >= len(p.content) {
+		return false, p.errorf("unexpected end of input")
+	}
+	switch p.content[p.pos] {
+	case ',':
+		p.pos++
+		return false, nil
+	case closing:
+		p.pos++
+		return true, nil
+	}
+	return false, p.errorf("expected ',' or %q", closing)
+}
+
+// string reads a string literal, returning its decoded value and raw span.
+func (p *jsonParser) string() (string, span, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.content); p.pos++ {
+		switch p.content[p.pos] {
+		case '\\':
+			p.pos++
+		case '"':
+			p.pos++
+			var value string
+			if err := json.Unmarshal([]byte(p.content[start:p.pos]), &value); err != nil {
+				return "", span{}, p.errorf("invalid string %s", p.content[start:p.pos])
+			}
+			return value, span{start, p.pos}, nil
+		case '\n':
+			return "", span{}, p.errorf("unterminated string")
+		}
+	}
+	return "", span{}, p.errorf("unterminated string")
+}
+
+// yamlNodes finds the keys and scalar values of every document in a YAML stream. Positions come from
+// yaml.v3; scalars whose raw text can't be pinned down, such as multi-line plain or quoted scalars,
+// are left alone.
+func yamlNodes(content string) ([]dataNode, error) {
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	y := &yamlWalker{content: content, lineStarts: lineStarts}
+
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		y.walk(&document, nil, false)
+	}
+	sortDataNodes(y.nodes)
+	return y.nodes, nil
+}
+
+type yamlWalker struct {
+	content    string
+	lineStarts []int
+	nodes      []dataNode
+}
+
+func (y *yamlWalker) walk(node *yaml.Node, path []string, key bool) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			y.walk(child, path, false)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := append(append([]string{}, path...), node.Content[i].Value)
+			y.walk(node.Content[i], keyPath, true)
+			y.walk(node.Content[i+1], keyPath, false)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			y.walk(child, append(append([]string{}, path...), strconv.Itoa(i)), false)
+		}
+	case yaml.ScalarNode:
+		y.scalar(node, path, key)
+	}
+}
+
+// yamlProperty matches the anchor and tag a scalar's position may start with.
+var yamlProperty = regexp.MustCompile(`^(?:[&!][^\s]*\s+)+`)
+
+// scalar adds the nodes of a scalar: the scalar itself, or every line of a block scalar.
+func (y *yamlWalker) scalar(node *yaml.Node, path []string, key bool) {
+	if node.Line < 1 || node.Line > len(y.lineStarts) {
+		return
+	}
+	lineStart := y.lineStarts[node.Line-1]
+	lineEnd := len(y.content)
+	if node.Line < len(y.lineStarts) {
+		lineEnd = y.lineStarts[node.Line] - 1
+	}
+	line := y.content[lineStart:lineEnd]
+	start := lineStart + runeOffset(line, node.Column-1)
+	start += len(yamlProperty.FindString(y.content[start:lineEnd]))
+	raw := y.content[start:lineEnd]
+
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		y.blockLines(node, path, line)
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		if end := closingQuote(raw, '"'); end > 0 {
+			y.nodes = append(y.nodes, dataNode{span: span{start, start + end}, key: key, path: path, value: node.Value, quote: quoteBasic})
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		if end := closingQuote(raw, '\''); end > 0 {
+			y.nodes = append(y.nodes, dataNode{span: span{start, start + end}, key: key, path: path, value: node.Value, quote: quoteYAMLSingle})
+		}
+	default:
+		if strings.HasPrefix(raw, node.Value) && node.Value != "" {
+			y.nodes = append(y.nodes, dataNode{span: span{start, start + len(node.Value)}, key: key, path: path, value: node.Value, quote: quoteYAMLPlain})
+		}
+	}
+}
+
+// blockLines adds the content lines of a literal or folded block scalar, which are more indented than
+// the line holding its | or > header.
+func (y *yamlWalker) blockLines(node *yaml.Node, path []string, header string) {
+	indent := len(header) - len(strings.TrimLeft(header, " "))
+	for i := node.Line; i < len(y.lineStarts); i++ {
+		lineStart := y.lineStarts[i]
+		lineEnd := len(y.content)
+		if i+1 < len(y.lineStarts) {
+			lineEnd = y.lineStarts[i+1] - 1
+		}
+		line := strings.TrimSuffix(y.content[lineStart:lineEnd], "\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" {
+			continue
+		}
+		if len(line)-len(text) <= indent {
+			return
+		}
+		start := lineStart + len(line) - len(text)
+		y.nodes = append(y.nodes, dataNode{span: span{start, start + len(text)}, path: path, value: text, quote: unchanged})
+	}
+}
+
+// runeOffset returns the byte offset of the n-th rune of s.
+func runeOffset(s string, n int) int {
+	offset := 0
+	for ; n > 0 && offset < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// closingQuote returns the offset just past the quote closing the string raw starts with, or 0 when
+// it isn't closed on the same line. Single-quoted strings escape quotes by doubling them.
+func closingQuote(raw string, quote byte) int {
+	for i := 1; i < len(raw); i++ {
+		switch {
+		case quote == '"' && raw[i] == '\\':
+			i++
+		case raw[i] == quote && quote == '\'' && i+1 < len(raw) && raw[i+1] == '\'':
+			i++
+		case raw[i] == quote:
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// quoteYAMLSingle spells s as a single-quoted YAML scalar, or double-quoted when it spans lines.
+func quoteYAMLSingle(s string) string {
+	if strings.ContainsAny(s, "\n\r") {
+		return quoteBasic(s)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteYAMLPlain keeps s a plain scalar unless YAML would read it differently, then quotes it.
+func quoteYAMLPlain(s string) string {
+	if s == "" || strings.ContainsAny(s, "\n\r\t") || strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") || strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") ||
+		strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) && !(s[0] == '-' && len(s) > 1 && s[1] != ' ') {
+		return quoteBasic(s)
+	}
+	return s
+}
+
+// sortDataNodes puts nodes in file order; yaml.v3 hands out keys and values in document order already,
+// but aliases and complex keys may not be.
+func sortDataNodes(nodes []dataNode) {
+	for i := 1; i < len(nodes); i++ {
+		for j := i; j > 0 && nodes[j].span.start < nodes[j-1].span.start; j-- {
+			nodes[j], nodes[j-1] = nodes[j-1], nodes[j]
+		}
+	}
+}
+
+// tomlParser finds the keys and values of a TOML document. BurntSushi/toml doesn't report positions,
+// so this is a small scanner of its own, lenient about anything it doesn't need to understand.
+type tomlParser struct {
+	content string
+	pos     int
+	nodes   []dataNode
+	tables  map[string]int // Elements seen so far of each array of tables.
+}
+
+func tomlNodes(content string) ([]dataNode, error) {
+	p := &tomlParser{content: content, tables: make(map[string]int)}
+	if strings.HasPrefix(content, byteOrderMark) {
+		p.pos = len(byteOrderMark)
+	}
+	var table []string
+	for {
+		p.skip(true)
+		if p.pos >= len(content) {
+			return p.nodes, nil
+		}
+		if content[p.pos] == '[' {
+			array := strings.HasPrefix(content[p.pos:], "[[")
+			p.pos++
+			if array {
+				p.pos++
+			}
+			keys, err := p.keys(nil)
+			if err != nil {
+				return nil, err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			p.skip(false)
+			if !strings.HasPrefix(content[p.pos:], closing) {
+				return nil, p.errorf("expected %q", closing)
+			}
+			p.pos += len(closing)
+			table = keys
+			if array {
+				name := strings.Join(keys, "\x00")
+				table = append(keys, strconv.Itoa(p.tables[name]))
+				p.tables[name]++
+			}
+			continue
+		}
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	line := 1 + strings.Count(p.content[:p.pos], "\n")
+	return fmt.Errorf("invalid TOML on line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skip skips spaces and comments, and line breaks too when newlines is set.
+func (p *tomlParser) skip(newlines bool) {
+	for p.pos < len(p.content) {
+		switch c := p.content[p.pos]; {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.content) && p.content[p.pos] != '\n' {
+				p.pos++
+			}
+		case newlines && (c == '\n' || c == '\r'):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// keyValue reads key = value, with path the table it belongs to.
+func (p *tomlParser) keyValue(path []string) error {
+	keys, err := p.keys(path)
+	if err != nil {
+		return err
+	}
+	p.skip(false)
+	if p.pos >= len(p.content) || p.content[p.pos] != '=' {
+		return p.errorf("expected '=' after a key")
+	}
+	p.pos++
+	p.skip(false)
+	return p.value(append(append([]string{}, path...), keys...))
+}
+
+// tomlBareKey matches keys that don't need quotes.
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// keys reads a dotted key, adding a key node for every part of it.
+func (p *tomlParser) keys(path []string) ([]string, error) {
+	var keys []string
+	for {
+		p.skip(false)
+		if p.pos >= len(p.content) {
+			return nil, p.errorf("expected a key")
+		}
+		start := p.pos
+		var key string
+		quote := quoteTOMLKey
+		switch p.content[p.pos] {
+		case '"', '\'':
+			value, end, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = value
+			if p.content[start] == '\'' {
+				quote = quoteTOMLLiteralString
+			}
+			p.pos = end
+		default:
+			for p.pos < len(p.content) && isBareKeyByte(p.content[p.pos]) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key")
+			}
+			key = p.content[start:p.pos]
+		}
+		keys = append(keys, key)
+		keyPath := append(append(append([]string{}, path...), keys[:len(keys)-1]...), key)
+		p.nodes = append(p.nodes, dataNode{span: span{start, p.pos}, key: true, path: keyPath, value: key, quote: quote})
+
+		p.skip(false)
+		if p.pos >= len(p.content) || p.content[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// quoteTOMLKey keeps s a bare key when it can be one.
+func quoteTOMLKey(s string) string {
+	if tomlBareKey.MatchString(s) {
+		return s
+	}
+	return quoteBasic(s)
+}
+
+// value reads a value, adding nodes for the strings and other scalars in it.
+func (p *tomlParser) value(path []string) error {
+	if p.pos >= len(p.content) {
+		return p.errorf("expected a value")
+	}
+	start := p.pos
+	switch c := p.content[p.pos]; c {
+	case '"', '\'':
+		multiline := strings.HasPrefix(p.content[p.pos:], strings.Repeat(string(c), 3))
+		value, end, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		p.pos = end
+		switch {
+		case multiline:
+			// Multi-line strings are replaced in their raw contents, which keeps their line continuations.
+			p.nodes = append(p.nodes, dataNode{span: span{start + 3, end - 3}, path: path, value: p.content[start+3 : end-3], quote: unchanged})
+		case c == '\'':
+			p.nodes = append(p.nodes, dataNode{span: span{start, end}, path: path, value: value, quote: quoteTOMLLiteralString})
+		default:
+			p.nodes = append(p.nodes, dataNode{span: span{start, end}, path: path, value: value, quote: quoteBasic})
+		}
+		return nil
+	case '[':
+		p.pos++
+		for index := 0; ; index++ {
+			p.skip(true)
+			if p.pos < len(p.content) && p.content[p.pos] == ']' {
+				p.pos++
+				return nil
+			}
+			if err := p.value(append(append([]string{}, path...), strconv.Itoa(index))); err != nil {
+				return err
+			}
+			p.skip(true)
+			if p.pos < len(p.content) && p.content[p.pos] == ',' {
+				p.pos++
+			}
+		}
+	case '{':
+		p.pos++
+		for {
+			p.skip(false)
+			if p.pos < len(p.content) && p.content[p.pos] == '}' {
+				p.pos++
+				return nil
+			}
+			if err := p.keyValue(path); err != nil {
+				return err
+			}
+			p.skip(false)
+			if p.pos < len(p.content) && p.content[p.pos] == ',' {
+				p.pos++
+			}
+		}
+	}
+	// Numbers, booleans and dates, which may hold a space: 1979-05-27 07:32:00Z.
+	for p.pos < len(p.content) && strings.IndexByte(",]}#\r\n", p.content[p.pos]) < 0 {
+		p.pos++
+	}
+	end := start + len(strings.TrimRight(p.content[start:p.pos], " \t"))
+	if end == start {
+		return p.errorf("expected a value")
+	}
+	p.nodes = append(p.nodes, dataNode{span: span{start, end}, path: path, value: p.content[start:end], quote: quoteTOMLLiteral})
+	return nil
+}
+
+// quoted reads a basic, literal or multi-line string at the current position, returning its value
+// and the offset just past it.
+func (p *tomlParser) quoted() (string, int, error) {
+	quote := p.content[p.pos]
+	delimiter := string(quote)
+	if strings.HasPrefix(p.content[p.pos:], strings.Repeat(delimiter, 3)) {
+		delimiter = strings.Repeat(delimiter, 3)
+	}
+	start := p.pos + len(delimiter)
+	for i := start; i < len(p.content); i++ {
+		switch {
+		case quote == '"' && p.content[i] == '\\':
+			i++
+		case len(delimiter) == 1 && p.content[i] == '\n':
+			return "", 0, p.errorf("unterminated string")
+		case strings.HasPrefix(p.content[i:], delimiter):
+			end := i + len(delimiter)
+			for len(delimiter) == 3 && end < len(p.content) && p.content[end] == quote {
+				end++ // Up to two quotes may end a multi-line string's contents: """a""""".
+			}
+			raw := p.content[start : end-len(delimiter)]
+			if quote == '\'' || len(delimiter) == 3 {
+				return raw, end, nil
+			}
+			value, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return "", 0, p.errorf("invalid string %q", raw)
+			}
+			return value, end, nil
+		}
+	}
+	return "", 0, p.errorf("unterminated string")
+}
+
+// quoteTOMLLiteralString spells s as a TOML literal string, or a basic one when it can't be.
+func quoteTOMLLiteralString(s string) string {
+	if strings.ContainsAny(s, "'\n\r") {
+		return quoteBasic(s)
+	}
+	return "'" + s + "'"
+}
+
+// quoteTOMLLiteral keeps numbers, booleans and dates bare, and turns anything else into a string.
+func quoteTOMLLiteral(s string) string {
+	if s == "true" || s == "false" || s == "inf" || s == "nan" || s == "+inf" || s == "-inf" {
+		return s
+	}
+	if s != "" && bytes.IndexByte([]byte("0123456789+-"), s[0]) >= 0 && !strings.ContainsAny(s, " \"'") {
+		return s
+	}
+	return quoteBasic(s)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dataShifter returns a shifter replacing in the parts of data files -data and -data-path select.
+func dataShifter(t *testing.T, data, dataPath string) *NameShifter {
+	t.Helper()
+	cfg := &Config{CaseMatching: true, Data: data, DataPath: dataPath}
+	if err := validateData(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return NewNameShifter(cfg, NewAppContext())
+}
+
+func TestDataKeysAndValuesOfJSON(t *testing.T) {
+	const doc = `{"timeout": "timeout", "list": ["timeout", 1, true]}`
+
+	values := dataShifter(t, dataValues, "")
+	if got := shiftFile(t, values, "a.json", doc, values.newRule("timeout", "delay")); got != `{"timeout": "delay", "list": ["delay", 1, true]}` {
+		t.Errorf("-data=values: %s", got)
+	}
+	keys := dataShifter(t, dataKeys, "")
+	if got := shiftFile(t, keys, "a.json", doc, keys.newRule("timeout", "delay")); got != `{"delay": "timeout", "list": ["timeout", 1, true]}` {
+		t.Errorf("-data=keys: %s", got)
+	}
+}
+
+func TestDataJSONEscapes(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+
+	// Rules see decoded text; only the strings they change are escaped again, untouched ones keep their spelling.
+	got := shiftFile(t, ns, "a.json", `{"a": "caf\u00e9 \"x\"", "b": "\u0041"}`, ns.newRule(`café "x"`, `a\b`))
+	if want := `{"a": "a\\b", "b": "\u0041"}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDataJSONLiteralTurnsIntoString(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+
+	got := shiftFile(t, ns, "a.json", `{"port": 8080, "debug": true}`, ns.newRule("8080", "9090"), ns.newRule("true", "yes"))
+	if want := `{"port": 9090, "debug": "yes"}`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDataYAMLKeepsCommentsAndQuoting(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+	doc := "# app: app\nname: app # app\nquoted: 'it''s app'\nescaped: \"app\\n\"\nscript: |\n  run app\n  stop app\nnext: app\n"
+
+	got := shiftFile(t, ns, "a.yml", doc, ns.newRule("app", "svc"))
+	want := "# app: app\nname: svc # app\nquoted: 'it''s svc'\nescaped: \"svc\\n\"\nscript: |\n  run svc\n  stop svc\nnext: svc\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDataYAMLPlainScalarsGetQuotedWhenNeeded(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+
+	tests := map[string]string{
+		"x: y":   "a: \"x: y\"\n",
+		"# note": "a: \"# note\"\n",
+		"plain":  "a: plain\n",
+	}
+	for replacement, want := range tests {
+		if got := shiftFile(t, ns, "a.yaml", "a: old\n", ns.newRule("old", replacement)); got != want {
+			t.Errorf("replacing with %q: got %q, want %q", replacement, got, want)
+		}
+	}
+}
+
+func TestDataTOML(t *testing.T) {
+	doc := "title = 'web' # web\n\n[web.server]\nweb.port = 80\n\n[[web]]\n\"web name\" = \"\"\"\nweb\n\"\"\"\n"
+
+	keys := dataShifter(t, dataKeys, "")
+	got := shiftFile(t, keys, "a.toml", doc, keys.newRule("web", "api"))
+	if want := "title = 'web' # web\n\n[api.server]\napi.port = 80\n\n[[api]]\n\"api name\" = \"\"\"\nweb\n\"\"\"\n"; got != want {
+		t.Errorf("-data=keys:\n%s", got)
+	}
+
+	values := dataShifter(t, dataValues, "")
+	got = shiftFile(t, values, "a.toml", doc, values.newRule("web", "it's"))
+	if want := "title = \"it's\" # web\n\n[web.server]\nweb.port = 80\n\n[[web]]\n\"web name\" = \"\"\"\nit's\n\"\"\"\n"; got != want {
+		t.Errorf("-data=values, where a literal string has to become a basic one:\n%s", got)
+	}
+}
+
+func TestDataPathSelectsValues(t *testing.T) {
+	ns := dataShifter(t, "", "services.*.image")
+	if ns.Config.Data != dataValues {
+		t.Fatalf("-data-path should imply -data=values, got %q", ns.Config.Data)
+	}
+
+	doc := "image: old\nservices:\n  web:\n    image: old\n    name: old\n  db:\n    image: old\n"
+	got := shiftFile(t, ns, "compose.yaml", doc, ns.newRule("old", "new"))
+	if want := "image: old\nservices:\n  web:\n    image: new\n    name: old\n  db:\n    image: new\n"; got != want {
+		t.Errorf("got:\n%s", got)
+	}
+}
+
+func TestDataNodePaths(t *testing.T) {
+	tests := []struct {
+		path, content string
+		want          []string
+	}{
+		{"a.json", `{"a": [{"b": 1}, "c"]}`, []string{"a", "a.0.b", "a.0.b", "a.1"}},
+		{"a.yaml", "a:\n  - b: 1\n  - c\n", []string{"a", "a.0.b", "a.0.b", "a.1"}},
+		{"a.toml", "[[a]]\nb = 1\n[[a]]\nc = [2, 3]\n", []string{"a", "a.0.b", "a.0.b", "a", "a.1.c", "a.1.c.0", "a.1.c.1"}},
+	}
+	for _, tt := range tests {
+		nodes, err := dataNodes(tt.path, tt.content)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		var got []string
+		for _, node := range nodes {
+			got = append(got, strings.Join(node.path, "."))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: paths = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchDataPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"a.b", "a.b", true},
+		{"a.*", "a.b", true},
+		{"a.*", "a.b.c", false},
+		{"**.image", "image", true},
+		{"**.image", "services.web.image", true},
+		{"a.**", "a", true},
+		{"a.**.c", "a.x.y.c", true},
+		{"a.**.c", "a.x.y", false},
+	}
+	for _, tt := range tests {
+		if got := matchDataPath(strings.Split(tt.pattern, "."), strings.Split(tt.path, ".")); got != tt.want {
+			t.Errorf("matchDataPath(%s, %s) = %v", tt.pattern, tt.path, got)
+		}
+	}
+}
+
+func TestDataLeavesOtherFilesAlone(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+
+	if got := shiftFile(t, ns, "notes.txt", "old\n", ns.newRule("old", "new")); got != "old\n" {
+		t.Errorf("notes.txt = %q", got)
+	}
+}
+
+func TestDataRejectsBrokenFiles(t *testing.T) {
+	for name, content := range map[string]string{
+		"a.json": `{"a": }`,
+		"a.yaml": "a: [b\n",
+		"a.toml": "a = \n",
+	} {
+		if _, err := dataNodes(name, content); err == nil {
+			t.Errorf("%s: expected a parse error", name)
+		}
+	}
+}
+
+func TestDataReportsBrokenFilesOnce(t *testing.T) {
+	ns := dataShifter(t, dataValues, "")
+	ns.Config.FileExtensions = []string{".json"}
+	path := filepath.Join(writeTree(t, map[string]string{"a.json": `{"a": }`}), "a.json")
+
+	ns.processSinglePath(path, []*Rule{ns.newRule("a", "b")})
+
+	report := ns.Context.errorReport.Render()
+	if ns.Context.errorsCount != 1 || strings.Count(report, path) != 1 {
+		t.Errorf("errors = %d, want 1 naming %s once:\n%s", ns.Context.errorsCount, path, report)
+	}
+}
+
+func TestValidateData(t *testing.T) {
+	if err := validateData(&Config{Data: "both"}); err == nil {
+		t.Error("accepted -data=both")
+	}
+	if err := validateData(&Config{Data: dataKeys, Scope: scopeComments}); err == nil {
+		t.Error("accepted -data combined with -scope")
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// validateEncoding checks the -encoding and -convert-to flags before anything is replaced.
+func validateEncoding(cfg *Config) error {
+	for _, name := range []string{cfg.Encoding, cfg.ConvertTo} {
+		if name == "" {
+			continue
+		}
+		if _, err := lookupEncoding(name, false); err != nil {
+			return err
+		}
+	}
+	if (cfg.Encoding != "" || cfg.ConvertTo != "") && cfg.Binary {
+		return fmt.Errorf("-binary works on raw bytes, it can't be combined with -encoding or -convert-to")
+	}
+	return nil
+}
+
+// lookupEncoding finds an encoding by name, e.g. "utf-16le", "latin1", "windows-1252" or "shift_jis". UTF-16
+// keeps a byte order mark it reads as a U+FEFF character, so it's written back as read; with bom set it's
+// expected and written instead, for converting to UTF-16.
+func lookupEncoding(name string, bom bool) (encoding.Encoding, error) {
+	policy := unicode.IgnoreBOM
+	if bom {
+		policy = unicode.UseBOM
+	}
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "utf-8", "utf8":
+		return unicode.UTF8, nil
+	case "utf-16", "utf-16le", "utf16", "utf16le":
+		return unicode.UTF16(unicode.LittleEndian, policy), nil
+	case "utf-16be", "utf16be":
+		return unicode.UTF16(unicode.BigEndian, policy), nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		// The WHATWG index behind htmlindex treats these as windows-1252, which can't round-trip every byte.
+		return charmap.ISO8859_1, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q, try e.g. utf-8, utf-16le, utf-16be, latin1, windows-1252 or shift_jis", name)
+	}
+	return enc, nil
+}
+
+// detectEncoding guesses the encoding of a file from its byte order mark, or failing that from its bytes:
+// UTF-16 text without a mark has a NUL in every other byte, as long as it's mostly ASCII; anything else
+// that isn't valid UTF-8 is taken for Latin-1, which every byte sequence is valid in.
+func detectEncoding(content []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(content, []byte(byteOrderMark)):
+		return unicode.UTF8
+	case bytes.HasPrefix(content, []byte{0xff, 0xfe}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(content, []byte{0xfe, 0xff}):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+
+	sample := content[:min(len(content), 4096)]
+	if len(sample) >= 2 && len(sample)%2 == 0 {
+		var evenNULs, oddNULs int
+		for i, b := range sample {
+			if b == 0 && i%2 == 0 {
+				evenNULs++
+			} else if b == 0 {
+				oddNULs++
+			}
+		}
+		half := len(sample) / 2
+		switch {
+		case oddNULs*5 > half*2 && evenNULs*10 < half:
+			return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+		case evenNULs*5 > half*2 && oddNULs*10 < half:
+			return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+		}
+	}
+	if utf8.Valid(content) {
+		return unicode.UTF8
+	}
+	return charmap.ISO8859_1
+}
+
+// sourceEncoding returns the encoding a file's content is read in: the one -encoding names, or else the
+// one detectEncoding guesses.
+func (ns *NameShifter) sourceEncoding(content []byte) encoding.Encoding {
+	if ns.Config.Encoding != "" {
+		source, _ := lookupEncoding(ns.Config.Encoding, false)
+		return source
+	}
+	return detectEncoding(content)
+}
+
+// decodesLosslessly reports whether content comes back byte for byte when decoded from enc and encoded
+// again. Bytes enc can't decode, such as an unpaired UTF-16 surrogate, turn into U+FFFD and would be lost
+// in writing the file back.
+func decodesLosslessly(content []byte, enc encoding.Encoding) bool {
+	if enc == unicode.UTF8 {
+		return true // UTF-8 content is matched as it is, never decoded.
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return false
+	}
+	encoded, err := enc.NewEncoder().Bytes(decoded)
+	return err == nil && bytes.Equal(encoded, content)
+}
+
+// checkLossless returns an error when content doesn't decode losslessly from the encoding it's read in,
+// so writing it back would lose bytes.
+func (ns *NameShifter) checkLossless(content []byte) error {
+	if source := ns.sourceEncoding(content); !decodesLosslessly(content, source) {
+		return fmt.Errorf("can't be read as %v without losing bytes, such as an unpaired UTF-16 surrogate; -encoding picks another encoding", source)
+	}
+	return nil
+}
+
+// fileTransforms returns the transformers decoding a file's content into the UTF-8 the rules are matched
+// against and encoding the result for writing it back, in the file's own encoding, or with -convert-to in
+// that one. Either is nil where no transformation is needed.
+func (ns *NameShifter) fileTransforms(content []byte) (decoder, encoder transform.Transformer) {
+	source := ns.sourceEncoding(content)
+	target := source
+	if ns.Config.ConvertTo != "" {
+		target, _ = lookupEncoding(ns.Config.ConvertTo, true)
+	}
+
+	if source != unicode.UTF8 {
+		decoder = source.NewDecoder()
+	}
+	if ns.Config.ConvertTo != "" {
+		// A converted file gets the byte order mark of its new encoding, if that has one, instead of its old one.
+		decoder = unicode.BOMOverride(source.NewDecoder())
+	}
+	if target != unicode.UTF8 {
+		encoder = target.NewEncoder()
+	}
+	return decoder, encoder
+}
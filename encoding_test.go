@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// utf16 spells s in UTF-16 with the given byte order, without a byte order mark.
+func utf16(t *testing.T, s string, order unicode.Endianness) []byte {
+	t.Helper()
+	encoded, err := unicode.UTF16(order, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+// shiftBytes is shiftFile for content that isn't UTF-8.
+func shiftBytes(t *testing.T, ns *NameShifter, name string, content []byte, rules ...*Rule) []byte {
+	t.Helper()
+	if err := validateEncoding(ns.Config); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.processFile(path, rules); err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rewritten
+}
+
+func TestEncodingRoundTrips(t *testing.T) {
+	latin1 := func(s string) []byte {
+		encoded, _ := charmap.ISO8859_1.NewEncoder().Bytes([]byte(s))
+		return encoded
+	}
+	tests := []struct {
+		name          string
+		before, after []byte
+	}{
+		{"utf-16le with bom", append([]byte{0xff, 0xfe}, utf16(t, "Grüße, old friend\n", unicode.LittleEndian)...),
+			append([]byte{0xff, 0xfe}, utf16(t, "Grüße, new friend\n", unicode.LittleEndian)...)},
+		{"utf-16be with bom", append([]byte{0xfe, 0xff}, utf16(t, "Grüße, old friend\n", unicode.BigEndian)...),
+			append([]byte{0xfe, 0xff}, utf16(t, "Grüße, new friend\n", unicode.BigEndian)...)},
+		{"utf-16le without bom", utf16(t, "key = old\nname = value\n", unicode.LittleEndian),
+			utf16(t, "key = new\nname = value\n", unicode.LittleEndian)},
+		{"latin-1", latin1("Café old, naïve\n"), latin1("Café new, naïve\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+			if got := shiftBytes(t, ns, "a.txt", tt.before, ns.newRule("old", "new")); !bytes.Equal(got, tt.after) {
+				t.Errorf("got  %q\nwant %q", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestEncodingRulesSeeDecodedText(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	// In UTF-16 "ü" is no byte sequence the UTF-8 search string has, so only decoding finds it.
+	content := append([]byte{0xff, 0xfe}, utf16(t, "Grüße\n", unicode.LittleEndian)...)
+	want := append([]byte{0xff, 0xfe}, utf16(t, "Hallo\n", unicode.LittleEndian)...)
+	if got := shiftBytes(t, ns, "a.txt", content, ns.newRule("Grüße", "Hallo")); !bytes.Equal(got, want) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEncodingFlagOverridesDetection(t *testing.T) {
+	// As UTF-8 these bytes are valid, as windows-1252 they're "Ã©".
+	ns := NewNameShifter(&Config{CaseMatching: true, Encoding: "windows-1252"}, NewAppContext())
+
+	if got := shiftBytes(t, ns, "a.txt", []byte("\xc3\xa9\n"), ns.newRule("Ã", "A")); !bytes.Equal(got, []byte("A\xa9\n")) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestConvertTo(t *testing.T) {
+	content := append([]byte{0xff, 0xfe}, utf16(t, "old ü\n", unicode.LittleEndian)...)
+
+	toUTF8 := NewNameShifter(&Config{CaseMatching: true, ConvertTo: "utf-8"}, NewAppContext())
+	if got := shiftBytes(t, toUTF8, "a.txt", content, toUTF8.newRule("old", "new")); !bytes.Equal(got, []byte("new ü\n")) {
+		t.Errorf("to utf-8: got %q", got)
+	}
+
+	toUTF16 := NewNameShifter(&Config{CaseMatching: true, ConvertTo: "utf-16be"}, NewAppContext())
+	want := append([]byte{0xfe, 0xff}, utf16(t, "new ü\n", unicode.BigEndian)...)
+	if got := shiftBytes(t, toUTF16, "a.txt", []byte("old ü\n"), toUTF16.newRule("old", "new")); !bytes.Equal(got, want) {
+		t.Errorf("to utf-16be: got %q", got)
+	}
+}
+
+func TestEncodingLeavesUnmatchedFilesUntouched(t *testing.T) {
+	// An unpaired surrogate decodes to U+FFFD, which would be written back in its place.
+	content := append(utf16(t, "nothing to see\n", unicode.LittleEndian), 0x00, 0xd8, 'x', 0)
+
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	if got := shiftBytes(t, ns, "a.txt", content, ns.newRule("old", "new")); !bytes.Equal(got, content) {
+		t.Errorf("got %q, want the file as it was", got)
+	}
+}
+
+func TestEncodingRefusesLossyDecoding(t *testing.T) {
+	content := append(utf16(t, "old friends, old times ", unicode.LittleEndian), 0x00, 0xd8, '\n', 0)
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	if err := ns.processFile(path, []*Rule{ns.newRule("old", "new")}); err == nil || !strings.Contains(err.Error(), "without losing bytes") {
+		t.Errorf("processFile = %v, want an error about the unpaired surrogate", err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, content) {
+		t.Errorf("got %q, want the file as it was", got)
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"utf-8 bom", []byte(byteOrderMark + "x"), "UTF-8"},
+		{"utf-8", []byte("naïve"), "UTF-8"},
+		{"utf-16le bom", []byte{0xff, 0xfe, 'x', 0}, "UTF-16LE"},
+		{"utf-16be bom", []byte{0xfe, 0xff, 0, 'x'}, "UTF-16BE"},
+		{"utf-16le ascii", utf16(t, "hello, world", unicode.LittleEndian), "UTF-16LE"},
+		{"utf-16be ascii", utf16(t, "hello, world", unicode.BigEndian), "UTF-16BE"},
+		{"short utf-16le", utf16(t, "hi", unicode.LittleEndian), "UTF-16LE"},
+		{"short utf-16be", utf16(t, "hi", unicode.BigEndian), "UTF-16BE"},
+		{"latin-1", []byte("na\xefve"), "ISO-8859-1"},
+	}
+	names := map[string]string{
+		"UTF-8":      "UTF-8",
+		"UTF-16LE":   "UTF-16LE (Ignore BOM)",
+		"UTF-16BE":   "UTF-16BE (Ignore BOM)",
+		"ISO-8859-1": "ISO 8859-1",
+	}
+	for _, tt := range tests {
+		if got := detectEncoding(tt.content); got.(interface{ String() string }).String() != names[tt.want] {
+			t.Errorf("%s: detected %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEncoding(t *testing.T) {
+	if err := validateEncoding(&Config{Encoding: "klingon"}); err == nil {
+		t.Error("accepted -encoding=klingon")
+	}
+	if err := validateEncoding(&Config{ConvertTo: "utf-8", Binary: true}); err == nil {
+		t.Error("accepted -convert-to with -binary")
+	}
+	if err := validateEncoding(&Config{Encoding: "Shift_JIS", ConvertTo: "UTF16BE"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Line ending conversions -eol accepts.
+const (
+	eolPreserve = "preserve"
+	eolLF       = "lf"
+	eolCRLF     = "crlf"
+)
+
+// validateEOL checks the -eol flag before anything is replaced.
+func validateEOL(cfg *Config) error {
+	cfg.EOL = strings.ToLower(cfg.EOL)
+	switch cfg.EOL {
+	case eolPreserve, eolLF, eolCRLF:
+	default:
+		return fmt.Errorf("invalid -eol %q, expected \"lf\", \"crlf\" or \"preserve\"", cfg.EOL)
+	}
+	if cfg.EOL != eolPreserve && cfg.Binary {
+		return errors.New("-binary files have no lines, -eol can't convert their line endings")
+	}
+	return nil
+}
+
+// splitLineEnding splits a line read up to and including its "\n" into its content and its line ending,
+// "\r\n", "\n", or nothing for a last line without one.
+func splitLineEnding(line string) (string, string) {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return line[:len(line)-2], "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// lineEnding returns the line ending to write in place of ending, which -eol may convert. A missing line
+// ending stays missing, converting line endings never adds a trailing newline.
+func (ns *NameShifter) lineEnding(ending string) string {
+	if ending == "" {
+		return ""
+	}
+	switch ns.Config.EOL {
+	case eolLF:
+		return "\n"
+	case eolCRLF:
+		return "\r\n"
+	}
+	return ending
+}
+
+// convertLineEndings converts every line ending of text the way -eol asks.
+func (ns *NameShifter) convertLineEndings(text string) string {
+	if ns.Config.EOL == eolPreserve {
+		return text
+	}
+	var b strings.Builder
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			b.WriteString(text)
+			break
+		}
+		content, ending := splitLineEnding(text[:i+1])
+		b.WriteString(content)
+		b.WriteString(ns.lineEnding(ending))
+		text = text[i+1:]
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Both the line by line and the whole file rewrite have to keep line endings, so every case runs through each.
+var rewriteModes = map[string]Config{
+	"lines":     {CaseMatching: true},
+	"multiline": {CaseMatching: true, Multiline: true},
+}
+
+func TestLineEndingsArePreserved(t *testing.T) {
+	tests := map[string]struct{ content, want string }{
+		"crlf":                 {"old\r\nold\r\n", "new\r\nnew\r\n"},
+		"mixed":                {"old\r\nold\nold\r\n", "new\r\nnew\nnew\r\n"},
+		"no trailing newline":  {"old\nold", "new\nnew"},
+		"crlf without last":    {"old\r\nold", "new\r\nnew"},
+		"lone carriage return": {"old\rold\n", "new\rnew\n"},
+		"blank lines":          {"\r\n\nold\r\n\r\n", "\r\n\nnew\r\n\r\n"},
+	}
+	for mode, cfg := range rewriteModes {
+		for name, tt := range tests {
+			cfg := cfg
+			ns := NewNameShifter(&cfg, NewAppContext())
+			if got := shiftFile(t, ns, "a.txt", tt.content, ns.newRule("old", "new")); got != tt.want {
+				t.Errorf("%s, %s: got %q, want %q", mode, name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestByteOrderMarkIsOutOfReach(t *testing.T) {
+	for mode, cfg := range rewriteModes {
+		cfg := cfg
+		cfg.Regex = true
+		ns := NewNameShifter(&cfg, NewAppContext())
+
+		// "^" matches where the text starts, after the mark, and the mark is written back as it was. Line by
+		// line every line is a text of its own.
+		got := shiftFile(t, ns, "a.txt", byteOrderMark+"old\r\nold\r\n", ns.newRule("^old", "new"))
+		want := byteOrderMark + "new\r\nold\r\n"
+		if !cfg.Multiline {
+			want = byteOrderMark + "new\r\nnew\r\n"
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", mode, got, want)
+		}
+	}
+}
+
+func TestConvertLineEndings(t *testing.T) {
+	content := "old\r\nold\nold"
+	tests := map[string]string{
+		eolLF:       "new\nnew\nnew",
+		eolCRLF:     "new\r\nnew\r\nnew",
+		eolPreserve: "new\r\nnew\nnew",
+	}
+	for mode, cfg := range rewriteModes {
+		for eol, want := range tests {
+			cfg := cfg
+			cfg.EOL = eol
+			ns := NewNameShifter(&cfg, NewAppContext())
+			if got := shiftFile(t, ns, "a.txt", content, ns.newRule("old", "new")); got != want {
+				t.Errorf("%s, -eol=%s: got %q, want %q", mode, eol, got, want)
+			}
+		}
+	}
+}
+
+func TestLineEndingsOfUTF16Files(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, EOL: eolPreserve}, NewAppContext())
+
+	content := append([]byte{0xff, 0xfe}, utf16(t, "old\r\nold\n", unicode.LittleEndian)...)
+	want := append([]byte{0xff, 0xfe}, utf16(t, "new\r\nnew\n", unicode.LittleEndian)...)
+	if got := shiftBytes(t, ns, "a.txt", content, ns.newRule("old", "new")); !bytes.Equal(got, want) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestAddressedLinesKeepTheirEndings(t *testing.T) {
+	ns := addressedShifter(t, Config{LineRanges: "2"})
+
+	if got := shiftFile(t, ns, "a.txt", "old\r\nold\r\nold", ns.newRule("old", "new")); got != "old\r\nnew\r\nold" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestValidateEOL(t *testing.T) {
+	cfg := &Config{EOL: "CRLF"}
+	if err := validateEOL(cfg); err != nil || cfg.EOL != eolCRLF {
+		t.Errorf("CRLF: %v, normalized to %q", err, cfg.EOL)
+	}
+	if err := validateEOL(&Config{EOL: "cr"}); err == nil {
+		t.Error("accepted -eol=cr")
+	}
+	if err := validateEOL(&Config{EOL: eolLF, Binary: true}); err == nil {
+		t.Error("accepted -eol with -binary")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// unescape interprets backslash escapes in a command line string: \n, \r, \t, \0, \\, \xHH for
+// a raw byte and \u{...} for a Unicode code point given in hex.
+func unescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", fmt.Errorf("%q ends with a lone backslash", s)
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '0':
+			b.WriteByte(0)
+		case '\\':
+			b.WriteByte('\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("%q has a truncated \\x escape, expected two hex digits", s)
+			}
+			value, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("%q has an invalid \\x escape: %w", s, err)
+			}
+			b.WriteByte(byte(value))
+			i += 2
+		case 'u':
+			end := strings.IndexByte(s[i:], '}')
+			if i+1 >= len(s) || s[i+1] != '{' || end < 0 {
+				return "", fmt.Errorf("%q has an invalid \\u escape, expected \\u{...}", s)
+			}
+			value, err := strconv.ParseUint(s[i+2:i+end], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(value)) {
+				return "", fmt.Errorf("%q has an invalid code point in \\u{%s}", s, s[i+2:i+end])
+			}
+			b.WriteRune(rune(value))
+			i += end
+		default:
+			return "", fmt.Errorf("%q has an unknown escape \\%c", s, s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// decodeHexString turns hex digits such as "09", "0x09 41" or "c3:a9" into the bytes they spell.
+func decodeHexString(s string) (string, error) {
+	digits := strings.NewReplacer("0x", "", "0X", "", " ", "", ":", "", "-", "").Replace(s)
+	decoded, err := hex.DecodeString(digits)
+	if err != nil {
+		return "", fmt.Errorf("%q isn't a valid hex string: %w", s, err)
+	}
+	return string(decoded), nil
+}
+
+// decodeArgument applies the requested input form to a command line search or replacement string.
+func (ns *NameShifter) decodeArgument(s string, isPattern bool) (string, error) {
+	switch {
+	case ns.Config.Hex:
+		return decodeHexString(s)
+	case ns.Config.Escapes && !(isPattern && ns.Config.Regex):
+		// Regex patterns understand \n, \t and \x{...} on their own, so only replacements are unescaped.
+		return unescape(s)
+	}
+	return s, nil
+}
+
+// displayString makes strings with control characters or raw bytes readable in reports.
+func displayString(s string) string {
+	if !utf8.ValidString(s) || strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
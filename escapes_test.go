@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		`tab\there`:       "tab\there",
+		`\r\n`:            "\r\n",
+		`nul\0`:           "nul\x00",
+		`back\\slash`:     `back\slash`,
+		`\xff\x41`:        "\xffA",
+		`\u{e9}t\u{e9}`:   "été",
+		`\u{1F600}`:       "😀",
+		`plain text only`: "plain text only",
+	}
+	for input, want := range tests {
+		got, err := unescape(input)
+		if err != nil {
+			t.Errorf("unescape(%q) failed: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("unescape(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestUnescapeErrors(t *testing.T) {
+	tests := map[string]string{
+		`trailing\`:    "lone backslash",
+		`\x4`:          "truncated",
+		`\xzz`:         "invalid \\x escape",
+		`\u00e9`:       "expected \\u{...}",
+		`\u{110000}`:   "invalid code point",
+		`\q`:           "unknown escape",
+		`\u{d800}text`: "invalid code point", // a lone surrogate isn't a character.
+	}
+	for input, want := range tests {
+		_, err := unescape(input)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("unescape(%q) error = %v, want one mentioning %q", input, err, want)
+		}
+	}
+}
+
+func TestDecodeHexString(t *testing.T) {
+	for _, input := range []string{"0d0a", "0x0d 0x0a", "0D:0A", "0d-0a"} {
+		got, err := decodeHexString(input)
+		if err != nil || got != "\r\n" {
+			t.Errorf("decodeHexString(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := decodeHexString("0d0"); err == nil {
+		t.Error("an odd number of digits should fail")
+	}
+}
+
+func TestDecodeArgumentLeavesRegexPatternsAlone(t *testing.T) {
+	ns := NewNameShifter(&Config{Escapes: true, Regex: true}, NewAppContext())
+
+	pattern, _ := ns.decodeArgument(`\t(\d)`, true)
+	replacement, _ := ns.decodeArgument(`\t$1`, false)
+	if pattern != `\t(\d)` {
+		t.Errorf("pattern = %q, RE2 should see the escapes itself", pattern)
+	}
+	if replacement != "\t$1" {
+		t.Errorf("replacement = %q", replacement)
+	}
+}
+
+func TestBuildRulesFromHex(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Hex: true, Multiline: true}, NewAppContext())
+
+	rules, err := ns.buildRules([]string{"dir", "0d 0a", "0a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules[0].Name != `"\r\n" → "\n"` {
+		t.Errorf("rule name = %s, control characters should be quoted", rules[0].Name)
+	}
+	if got := shiftFile(t, ns, "dos.md", "one\r\ntwo\r\n", rules...); got != "one\ntwo\n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+// nearMiss is a spelling found within -fuzzy edits of a rule's search string, e.g. "recieve" for "receive".
+type nearMiss struct {
+	rule      *Rule
+	variant   string
+	distance  int
+	locations []string // path:line:column of every occurrence.
+}
+
+// validateFuzzy checks the -fuzzy flag against the rules before any file is read. A distance as long as
+// the search string would let anything of about its length match.
+func validateFuzzy(cfg *Config, rules []*Rule) error {
+	if cfg.Fuzzy < 0 {
+		return fmt.Errorf("invalid -fuzzy %d, it must be positive", cfg.Fuzzy)
+	}
+	if cfg.Fuzzy == 0 {
+		if cfg.Accept != "" {
+			return fmt.Errorf("-accept needs -fuzzy to find near misses to accept")
+		}
+		return nil
+	}
+	for _, rule := range rules {
+		if !rule.Regex && utf8.RuneCountInString(rule.Search) <= cfg.Fuzzy {
+			return fmt.Errorf("-fuzzy %d is too loose for %q, it must be shorter than the search string", cfg.Fuzzy, rule.Search)
+		}
+	}
+	return nil
+}
+
+// findNearMisses reads the files the run would process and finds near misses of every literal rule in
+// them, grouped by spelling. Regex rules are left out, a pattern has no single spelling to miss.
+func (ns *NameShifter) findNearMisses(paths []string, rules []*Rule) []*nearMiss {
+	var misses []*nearMiss
+	found := make(map[*Rule]map[string]*nearMiss)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || !ns.shouldProcessFile(path, info) || officeFormatOf(path) != nil {
+			continue
+		}
+		if err := ns.ignoreConfigDirs(path, nil); err != nil {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			ns.reportError(path, err)
+			continue
+		}
+		if decoder, _ := ns.fileTransforms(content); decoder != nil {
+			if content, _, err = transform.Bytes(decoder, content); err != nil {
+				ns.reportError(path, err)
+				continue
+			}
+		}
+		// A byte order mark isn't text, and would shift the columns of the first line.
+		text := strings.TrimPrefix(string(content), byteOrderMark)
+
+		for _, rule := range ns.rulesFor(path, rules) {
+			if rule.Regex {
+				continue
+			}
+			for _, match := range fuzzyMatches(text, rule, ns.Config.Fuzzy, ns.findMatches(text, rule)) {
+				variant := text[match.start:match.end]
+				key := variant
+				if !rule.CaseMatching {
+					key = strings.ToLower(variant)
+				}
+				if found[rule] == nil {
+					found[rule] = make(map[string]*nearMiss)
+				}
+				miss, ok := found[rule][key]
+				if !ok {
+					miss = &nearMiss{rule: rule, variant: variant, distance: match.distance}
+					found[rule][key] = miss
+					misses = append(misses, miss)
+				}
+				line := strings.Count(text[:match.start], "\n") + 1
+				column := utf8.RuneCountInString(text[strings.LastIndexByte(text[:match.start], '\n')+1:match.start]) + 1
+				miss.locations = append(miss.locations, fmt.Sprintf("%s:%d:%d", ns.displayPath(path), line, column))
+			}
+		}
+	}
+	sort.SliceStable(misses, func(i, j int) bool { return len(misses[i].locations) > len(misses[j].locations) })
+	return misses
+}
+
+// displayPath returns path relative to the starting directory, for reports.
+func (ns *NameShifter) displayPath(path string) string {
+	if rel, err := filepath.Rel(ns.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// fuzzyMatch is a near miss of a search string in a text.
+type fuzzyMatch struct {
+	span
+	distance int
+}
+
+// fuzzyMatches finds the spellings within maxDistance edits of the rule's search string in text, counting
+// insertions, deletions and substitutions of a character and swaps of two neighbouring ones as one edit
+// each, since those are the typos people make. They must sit on the rule's
+// word boundaries, or on identifier boundaries if it has none, so "recieveMessage" yields "recieve" but
+// "receipt" doesn't yield "recei". Anything overlapping one of the rule's own matches is left out, the
+// rule takes care of those, and so is the replacement itself, which the rule was written to produce.
+func fuzzyMatches(text string, rule *Rule, maxDistance int, exact [][]int) []fuzzyMatch {
+	mode := rule.Boundary
+	if mode == boundaryNone {
+		mode = boundaryIdentifier
+	}
+	fold := func(r rune) rune { return r }
+	if !rule.CaseMatching {
+		fold = unicode.ToLower
+	}
+
+	needle := []rune(rule.Search)
+	for i, r := range needle {
+		needle[i] = fold(r)
+	}
+	var runes []rune
+	var offsets []int
+	for offset, r := range text {
+		runes = append(runes, fold(r))
+		offsets = append(offsets, offset)
+	}
+	offsets = append(offsets, len(text))
+
+	startsWord, endsWord := isWordRune(needle[0]), isWordRune(needle[len(needle)-1])
+	var fuzzy []fuzzyMatch
+	older, previous, current := make([]int, len(needle)+1), make([]int, len(needle)+1), make([]int, len(needle)+1)
+	for i := range runes {
+		if isWordRune(runes[i]) != startsWord || !boundaryBefore(text, offsets[i], mode) {
+			continue
+		}
+
+		// previous[j] is the distance between needle[:j] and the k runes of text from i on.
+		for j := range previous {
+			previous[j] = j
+		}
+		best := fuzzyMatch{distance: maxDistance + 1}
+		for k := 1; k <= len(needle)+maxDistance && i+k <= len(runes); k++ {
+			current[0] = k
+			lowest := k
+			for j := 1; j <= len(needle); j++ {
+				cost := 1
+				if needle[j-1] == runes[i+k-1] {
+					cost = 0
+				}
+				current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+				if j > 1 && k > 1 && needle[j-1] == runes[i+k-2] && needle[j-2] == runes[i+k-1] {
+					current[j] = min(current[j], older[j-2]+1)
+				}
+				lowest = min(lowest, current[j])
+			}
+			older, previous, current = previous, current, older
+			if lowest > maxDistance {
+				break // Every longer stretch of text is further away still.
+			}
+
+			distance := previous[len(needle)]
+			if distance > best.distance || distance == best.distance && abs(k-len(needle)) >= abs(best.end-best.start-len(needle)) {
+				continue
+			}
+			if isWordRune(runes[i+k-1]) != endsWord || !boundaryAfter(text, offsets[i+k], mode) {
+				continue
+			}
+			best = fuzzyMatch{span{i, i + k}, distance}
+		}
+
+		if best.distance > 0 && best.distance <= maxDistance {
+			fuzzy = append(fuzzy, best)
+		}
+	}
+
+	var matches []fuzzyMatch
+	last := 0
+	for _, match := range fuzzy {
+		if match.start < last {
+			continue
+		}
+		variant := text[offsets[match.start]:offsets[match.end]]
+		if overlapsAny(span{offsets[match.start], offsets[match.end]}, exact) {
+			continue
+		}
+		if variant == rule.Replacement || !rule.CaseMatching && strings.EqualFold(variant, rule.Replacement) {
+			continue
+		}
+		last = match.end
+		match.span = span{offsets[match.start], offsets[match.end]}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+func overlapsAny(s span, matches [][]int) bool {
+	for _, match := range matches {
+		if s.start < match[1] && match[0] < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// acceptNearMisses turns the near misses -accept names, or all of them for "all", into rules replacing
+// them like the rule they were found for. Accepted spellings no near miss has are returned as well.
+func (ns *NameShifter) acceptNearMisses(misses []*nearMiss) ([]*Rule, []string) {
+	accepted := make(map[string]bool)
+	for _, variant := range strings.Split(ns.Config.Accept, ",") {
+		if variant = strings.TrimSpace(variant); variant != "" {
+			accepted[variant] = true
+		}
+	}
+
+	var rules []*Rule
+	used := make(map[string]bool)
+	for _, miss := range misses {
+		if !accepted["all"] && !accepted[miss.variant] {
+			continue
+		}
+		used[miss.variant] = true
+		rule := *miss.rule
+		rule.Search = miss.variant
+		rule.Name = fmt.Sprintf("%s → %s", displayString(rule.Search), displayString(rule.Replacement))
+		rules = append(rules, &rule)
+	}
+
+	var unknown []string
+	for variant := range accepted {
+		if variant != "all" && !used[variant] {
+			unknown = append(unknown, variant)
+		}
+	}
+	sort.Strings(unknown)
+	return rules, unknown
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// nearMissesIn returns the spellings within distance edits of search that fuzzyMatches finds in text.
+func nearMissesIn(ns *NameShifter, text, search, replacement string, distance int) []string {
+	rule := ns.newRule(search, replacement)
+	var variants []string
+	for _, match := range fuzzyMatches(text, rule, distance, ns.findMatches(text, rule)) {
+		variants = append(variants, text[match.start:match.end])
+	}
+	return variants
+}
+
+func TestFuzzyMatchesCountTypos(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	text := "receive recieve receve receeive recieev reciept"
+
+	// A swap of neighbouring letters is one edit, so "recieev" is two away and "reciept" three.
+	if got, want := nearMissesIn(ns, text, "receive", "accept", 1), []string{"recieve", "receve", "receeive"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("within 1: %q, want %q", got, want)
+	}
+	if got, want := nearMissesIn(ns, text, "receive", "accept", 2), []string{"recieve", "receve", "receeive", "recieev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("within 2: %q, want %q", got, want)
+	}
+}
+
+func TestFuzzyMatchesStayOnIdentifierBoundaries(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	// Inside recieveMessage the near miss ends at the hump; "receipt" holds no word ending near "receive".
+	got := nearMissesIn(ns, "recieveMessage on_recieve receipt", "receive", "accept", 1)
+	if want := []string{"recieve", "recieve"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFuzzyMatchesSkipTheReplacement(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false}, NewAppContext())
+
+	// colour → color: "color" is one edit from "colour", but it's what the rule produces.
+	if got := nearMissesIn(ns, "Color colur COLOUR", "colour", "color", 1); !reflect.DeepEqual(got, []string{"colur"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFindNearMissesGroupsBySpelling(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.go":        "// adress\nvar adress = \"address\"\n",
+		"b.md":        "Your adress or Adress.\n",
+		"c.txt":       "adress\n",
+		".git/config": "adress\n",
+	})
+	ns := NewNameShifter(&Config{CaseMatching: false, Fuzzy: 1, IgnoreConfig: true, FileExtensions: []string{".go", ".md"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	misses := ns.findNearMisses(paths, []*Rule{ns.newRule("address", "location")})
+
+	if len(misses) != 1 {
+		t.Fatalf("got %d near misses, want adress in any case", len(misses))
+	}
+	want := []string{"a.go:1:4", "a.go:2:5", "b.md:1:6", "b.md:1:16"}
+	if miss := misses[0]; miss.variant != "adress" || miss.distance != 1 || !reflect.DeepEqual(miss.locations, want) {
+		t.Errorf("near miss = %q at %q (distance %d), want adress at %q", miss.variant, miss.locations, miss.distance, want)
+	}
+}
+
+func TestFindNearMissesDecodesFiles(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt": string(append([]byte{0xff, 0xfe}, utf16(t, "Straße\nadress\n", unicode.LittleEndian)...)),
+		"b.txt": "\xe9t\xe9 adress\n", // Latin-1.
+	})
+	ns := NewNameShifter(&Config{CaseMatching: true, Fuzzy: 1, FileExtensions: []string{".txt"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	misses := ns.findNearMisses(paths, []*Rule{ns.newRule("address", "location")})
+
+	if len(misses) != 1 {
+		t.Fatalf("got %d near misses, want adress", len(misses))
+	}
+	if got, want := misses[0].locations, []string{"a.txt:2:1", "b.txt:1:5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("locations = %q, want %q", got, want)
+	}
+}
+
+func TestAcceptNearMisses(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Accept: "recieve, recive, recevie"}, NewAppContext())
+	rule := ns.newRule("receive", "accept")
+	misses := []*nearMiss{{rule: rule, variant: "recieve"}, {rule: rule, variant: "recive"}, {rule: rule, variant: "receve"}}
+
+	rules, unknown := ns.acceptNearMisses(misses)
+
+	if len(rules) != 2 || rules[0].Search != "recieve" || rules[1].Search != "recive" || rules[1].Replacement != "accept" {
+		t.Errorf("accepted rules = %v", rules)
+	}
+	if !reflect.DeepEqual(unknown, []string{"recevie"}) {
+		t.Errorf("unknown = %q", unknown)
+	}
+	if got := ns.applyRules("recieve recive receve", rules, newFileState("")); got != "accept accept receve" {
+		t.Errorf("accepted rules replace %q", got)
+	}
+
+	ns.Config.Accept = "all"
+	if rules, _ := ns.acceptNearMisses(misses); len(rules) != 3 {
+		t.Errorf("-accept=all accepted %d near misses, want 3", len(rules))
+	}
+}
+
+func TestValidateFuzzy(t *testing.T) {
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	rules := []*Rule{ns.newRule("id", "key")}
+
+	if err := validateFuzzy(&Config{Fuzzy: 2}, rules); err == nil {
+		t.Error("accepted -fuzzy as long as the search string")
+	}
+	if err := validateFuzzy(&Config{Fuzzy: -1}, nil); err == nil {
+		t.Error("accepted a negative -fuzzy")
+	}
+	if err := validateFuzzy(&Config{Accept: "all"}, nil); err == nil {
+		t.Error("accepted -accept without -fuzzy")
+	}
+	if err := validateFuzzy(&Config{Fuzzy: 1}, rules); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,8 +3,12 @@ module github.com/theHamdiz/nsh
 go 1.22.1
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/fatih/color v1.16.0
 	github.com/jedib0t/go-pretty/v6 v6.5.5
+	golang.org/x/mod v0.21.0
+	golang.org/x/text v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
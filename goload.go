@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// goPackage is one type-checked package. A directory can yield three of them: the package itself,
+// its test variant with the in-package _test.go files added, and the external _test package.
+type goPackage struct {
+	path     string // Import path, with "_test" appended for external test packages.
+	dir      string
+	files    []*ast.File
+	types    *types.Package
+	info     *types.Info
+	errs     []error
+	checking bool
+}
+
+// parsedDir holds a directory's parsed Go files, split the way the go tool splits them.
+type parsedDir struct {
+	files, tests, xtests []*ast.File
+}
+
+// goProgram is the Go code nsh loaded for a semantic operation: every package under the starting
+// directory, plus whatever packages of the same module they import, loaded on demand.
+type goProgram struct {
+	fset       *token.FileSet
+	rootDir    string // Absolute starting directory, only files below it are rewritten.
+	moduleDir  string
+	modulePath string
+	parsed     map[string]*parsedDir // Parsed directories keyed by absolute path.
+	imported   map[string]*goPackage // Importable packages of the module keyed by import path.
+	roots      []*goPackage          // Packages found under rootDir, test variants included.
+	fallback   types.ImporterFrom    // Type-checks the standard library and other modules from source.
+}
+
+// findGoModule looks for the go.mod governing dir and returns its directory and module path.
+func findGoModule(dir string) (string, string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		data, err := os.ReadFile(filepath.Join(current, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(data)
+			if modulePath == "" {
+				return "", "", fmt.Errorf("%s has no module directive", filepath.Join(current, "go.mod"))
+			}
+			return current, modulePath, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+		if filepath.Dir(current) == current {
+			return "", "", fmt.Errorf("no go.mod found in %s or any of its parents", dir)
+		}
+	}
+}
+
+// loadGoProgram parses and type-checks every package under startingDir. Type errors are collected
+// rather than returned, see typeErrors.
+func loadGoProgram(startingDir string) (*goProgram, error) {
+	rootDir, err := filepath.Abs(startingDir)
+	if err != nil {
+		return nil, err
+	}
+	moduleDir, modulePath, err := findGoModule(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prog := &goProgram{
+		fset:       token.NewFileSet(),
+		rootDir:    rootDir,
+		moduleDir:  moduleDir,
+		modulePath: modulePath,
+		parsed:     make(map[string]*parsedDir),
+		imported:   make(map[string]*goPackage),
+	}
+	prog.fallback = importer.ForCompiler(prog.fset, "source", nil).(types.ImporterFrom)
+
+	err = filepath.WalkDir(rootDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != rootDir {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir // The go tool ignores these too.
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir // A nested module is a program of its own.
+			}
+		}
+		return prog.addRoots(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range prog.roots {
+		if pkg.types == nil {
+			prog.check(pkg)
+		}
+	}
+	return prog, nil
+}
+
+// addRoots adds the packages of dir, if any, to the program's roots.
+func (prog *goProgram) addRoots(dir string) error {
+	parsed, err := prog.parseDir(dir)
+	if err != nil {
+		return err
+	}
+	importPath := prog.importPathOf(dir)
+	if len(parsed.files) > 0 {
+		prog.roots = append(prog.roots, prog.importable(importPath, dir, parsed))
+	}
+	if len(parsed.tests) > 0 {
+		files := append(append([]*ast.File{}, parsed.files...), parsed.tests...)
+		prog.roots = append(prog.roots, &goPackage{path: importPath, dir: dir, files: files})
+	}
+	if len(parsed.xtests) > 0 {
+		prog.roots = append(prog.roots, &goPackage{path: importPath + "_test", dir: dir, files: parsed.xtests})
+	}
+	return nil
+}
+
+// importable returns the package other packages get when importing importPath.
+func (prog *goProgram) importable(importPath, dir string, parsed *parsedDir) *goPackage {
+	pkg, ok := prog.imported[importPath]
+	if !ok {
+		pkg = &goPackage{path: importPath, dir: dir, files: parsed.files}
+		prog.imported[importPath] = pkg
+	}
+	return pkg
+}
+
+// parseDir parses the Go files of dir that match the current build context, once.
+func (prog *goProgram) parseDir(dir string) (*parsedDir, error) {
+	if parsed, ok := prog.parsed[dir]; ok {
+		return parsed, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &parsedDir{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, entry.Name()); err != nil || !match {
+			continue // Build constraints exclude it, e.g. another GOOS.
+		}
+		path := filepath.Join(dir, entry.Name())
+		file, err := parser.ParseFile(prog.fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !strings.HasSuffix(entry.Name(), "_test.go"):
+			parsed.files = append(parsed.files, file)
+		case strings.HasSuffix(file.Name.Name, "_test"):
+			parsed.xtests = append(parsed.xtests, file)
+		default:
+			parsed.tests = append(parsed.tests, file)
+		}
+	}
+
+	prog.parsed[dir] = parsed
+	return parsed, nil
+}
+
+// importPathOf returns the import path of the package in dir.
+func (prog *goProgram) importPathOf(dir string) string {
+	rel, err := filepath.Rel(prog.moduleDir, dir)
+	if err != nil || rel == "." {
+		return prog.modulePath
+	}
+	return prog.modulePath + "/" + filepath.ToSlash(rel)
+}
+
+// inModule reports whether importPath belongs to the program's module.
+func (prog *goProgram) inModule(importPath string) bool {
+	return importPath == prog.modulePath || strings.HasPrefix(importPath, prog.modulePath+"/")
+}
+
+// check type-checks pkg, collecting its type errors.
+func (prog *goProgram) check(pkg *goPackage) {
+	pkg.checking = true
+	defer func() { pkg.checking = false }()
+
+	pkg.info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{
+		Importer:    prog,
+		FakeImportC: true,
+		Error:       func(err error) { pkg.errs = append(pkg.errs, err) },
+	}
+	pkg.types, _ = conf.Check(pkg.path, prog.fset, pkg.files, pkg.info)
+}
+
+// Import implements types.Importer.
+func (prog *goProgram) Import(path string) (*types.Package, error) {
+	return prog.ImportFrom(path, "", 0)
+}
+
+// ImportFrom implements types.ImporterFrom. Packages of the module are loaded by the program itself,
+// so objects are shared with the packages importing them; anything else is left to the fallback.
+func (prog *goProgram) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if !prog.inModule(path) {
+		return prog.fallback.ImportFrom(path, dir, mode)
+	}
+
+	pkg, ok := prog.imported[path]
+	if !ok {
+		pkgDir := filepath.Join(prog.moduleDir, filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(path, prog.modulePath), "/")))
+		parsed, err := prog.parseDir(pkgDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed.files) == 0 {
+			return nil, fmt.Errorf("no Go files in %s", pkgDir)
+		}
+		pkg = prog.importable(path, pkgDir, parsed)
+	}
+	if pkg.checking {
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	if pkg.types == nil {
+		prog.check(pkg)
+	}
+	return pkg.types, nil
+}
+
+// packages returns every type-checked package, roots first.
+func (prog *goProgram) packages() []*goPackage {
+	all := append([]*goPackage{}, prog.roots...)
+	for _, pkg := range prog.imported {
+		if pkg.types != nil && !containsPackage(prog.roots, pkg) {
+			all = append(all, pkg)
+		}
+	}
+	return all
+}
+
+func containsPackage(packages []*goPackage, pkg *goPackage) bool {
+	for _, p := range packages {
+		if p == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// typeErrors returns the type errors found in the packages under the starting directory.
+func (prog *goProgram) typeErrors() []error {
+	var errs []error
+	for _, pkg := range prog.roots {
+		errs = append(errs, pkg.errs...)
+	}
+	return errs
+}
+
+// underRoot reports whether the file at pos lives below the starting directory.
+func (prog *goProgram) underRoot(pos token.Pos) bool {
+	filename := prog.fset.PositionFor(pos, false).Filename
+	return filename != "" && strings.HasPrefix(filename, prog.rootDir+string(filepath.Separator))
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// goModule is a go.mod found while migrating, with the packages below it.
+type goModule struct {
+	dir      string
+	path     string
+	packages []string // Directories holding Go files, relative to dir.
+}
+
+// packageMove is a package whose import path changes, and whose directory may move with it.
+type packageMove struct {
+	from, to       string // Import paths.
+	fromDir, toDir string
+}
+
+// modulePathMigration rewrites every reference to one import path prefix into another.
+type modulePathMigration struct {
+	from, to string
+	modules  []*goModule
+	goFiles  []string
+}
+
+// migrateModulePath implements the go-module subcommand: go-module <startingDir> <oldPath> <newPath>. The old
+// path can be a module's path, renaming the module, a path inside a module, moving that part of the
+// module's directory tree, or any other path, such as a dependency moving to a fork.
+func (ns *NameShifter) migrateModulePath(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: nsh go-module <startingDirectory> <oldImportPath> <newImportPath>")
+	}
+	startingDir, from, to := args[0], strings.TrimSuffix(args[1], "/"), strings.TrimSuffix(args[2], "/")
+	for _, path := range []string{from, to} {
+		if err := module.CheckImportPath(path); err != nil {
+			return err
+		}
+	}
+	if from == to {
+		return fmt.Errorf("%s is already the import path", from)
+	}
+	if hasPathPrefix(to, from) {
+		return fmt.Errorf("can't move %s into itself as %s", from, to)
+	}
+
+	migration := &modulePathMigration{from: from, to: to}
+	if err := migration.scan(startingDir); err != nil {
+		return err
+	}
+	moves, err := migration.plan()
+	if err != nil {
+		return err
+	}
+
+	// Every change is worked out before anything is written, so a file that can't be migrated leaves the
+	// tree as it was rather than half migrated.
+	var rewrites []fileRewrite
+	failed := false
+	for _, path := range migration.goFiles {
+		rewrite, err := rewriteImports(path, from, to)
+		if err != nil {
+			ns.Context.AddError()
+			ns.Context.AddErrorReportRow([]table.Row{{"Path", path, "Error", err.Error()}})
+			failed = true
+			continue
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	for _, mod := range migration.modules {
+		rewrite, err := rewriteGoMod(filepath.Join(mod.dir, "go.mod"), from, to)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, rewrite)
+	}
+	if failed {
+		return errors.New("some Go files can't be migrated, nothing was changed")
+	}
+
+	for _, rewrite := range rewrites {
+		if rewrite.count == 0 {
+			continue
+		}
+		if err := ns.writeFileContent(rewrite.path, rewrite.content); err != nil {
+			return err
+		}
+		for i := 0; i < rewrite.count; i++ {
+			ns.Context.AddReplacement()
+		}
+	}
+	moved := make(map[string]bool)
+	for _, move := range moves {
+		if move.fromDir == "" || moved[move.fromDir] {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(move.toDir), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(move.fromDir, move.toDir); err != nil {
+			return err
+		}
+		moved[move.fromDir] = true
+	}
+
+	ns.Context.MovedPackagesReport(moves)
+	return nil
+}
+
+// hasPathPrefix reports whether path is prefix or lies below it.
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// replacePathPrefix swaps the from prefix of path for to, reporting whether path had it.
+func replacePathPrefix(path, from, to string) (string, bool) {
+	if !hasPathPrefix(path, from) {
+		return path, false
+	}
+	return to + strings.TrimPrefix(path, from), true
+}
+
+// scan finds the go.mod files and Go files under startingDir, and the package directories of each module.
+func (m *modulePathMigration) scan(startingDir string) error {
+	err := filepath.WalkDir(startingDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != startingDir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir // The go tool ignores these too.
+			}
+			return nil
+		}
+		switch {
+		case d.Name() == "go.mod":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			modulePath := modfile.ModulePath(data)
+			if modulePath == "" {
+				return fmt.Errorf("%s has no module directive", path)
+			}
+			m.modules = append(m.modules, &goModule{dir: filepath.Dir(path), path: modulePath})
+		case strings.HasSuffix(d.Name(), ".go"):
+			m.goFiles = append(m.goFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(m.modules) == 0 {
+		return fmt.Errorf("no go.mod found under %s", startingDir)
+	}
+
+	// Each package belongs to the innermost module containing it.
+	sort.Slice(m.modules, func(i, j int) bool { return len(m.modules[i].dir) > len(m.modules[j].dir) })
+	seen := make(map[string]bool)
+	for _, path := range m.goFiles {
+		dir := filepath.Dir(path)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		for _, mod := range m.modules {
+			if rel, err := filepath.Rel(mod.dir, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				mod.packages = append(mod.packages, filepath.ToSlash(rel))
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// plan lists the packages whose import path changes. Renaming a module changes the import path of every
+// package in it; moving a path inside a module moves the directories below it, which mustn't exist yet.
+func (m *modulePathMigration) plan() ([]packageMove, error) {
+	var moves []packageMove
+	for _, mod := range m.modules {
+		movesDirs := !hasPathPrefix(mod.path, m.from) && hasPathPrefix(m.from, mod.path)
+		if movesDirs && !hasPathPrefix(m.to, mod.path) {
+			return nil, fmt.Errorf("%s can only move within its module %s", m.from, mod.path)
+		}
+
+		for _, rel := range mod.packages {
+			importPath := mod.path
+			if rel != "." {
+				importPath += "/" + rel
+			}
+			newPath, ok := replacePathPrefix(importPath, m.from, m.to)
+			if !ok {
+				continue
+			}
+			move := packageMove{from: importPath, to: newPath}
+			if movesDirs {
+				move.fromDir = filepath.Join(mod.dir, filepath.FromSlash(strings.TrimPrefix(m.from, mod.path+"/")))
+				move.toDir = filepath.Join(mod.dir, filepath.FromSlash(strings.TrimPrefix(m.to, mod.path+"/")))
+				if _, err := os.Stat(move.toDir); err == nil {
+					return nil, fmt.Errorf("can't move %s to %s, it already exists", move.fromDir, move.toDir)
+				}
+			}
+			moves = append(moves, move)
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].from < moves[j].from })
+	return moves, nil
+}
+
+// fileRewrite is the new content of a file the migration changes, with the number of paths it replaced.
+type fileRewrite struct {
+	path    string
+	content []byte
+	count   int
+}
+
+// rewriteImports rewrites the import specs of a Go file whose path starts with from. Only the path
+// literals change; a file that was gofmt'd before is gofmt'd again, so imports stay sorted in their groups.
+func rewriteImports(path, from, to string) (fileRewrite, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileRewrite{}, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return fileRewrite{}, err
+	}
+
+	var b bytes.Buffer
+	last, count := 0, 0
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		newPath, ok := replacePathPrefix(importPath, from, to)
+		if !ok {
+			continue
+		}
+		start, end := fset.PositionFor(spec.Path.Pos(), false).Offset, fset.PositionFor(spec.Path.End(), false).Offset
+		b.Write(content[last:start])
+		b.WriteString(strconv.Quote(newPath))
+		last = end
+		count++
+	}
+	if count == 0 {
+		return fileRewrite{path: path}, nil
+	}
+	b.Write(content[last:])
+
+	rewritten := b.Bytes()
+	if formatted, err := format.Source(content); err == nil && bytes.Equal(formatted, content) {
+		if rewritten, err = format.Source(rewritten); err != nil {
+			return fileRewrite{}, err
+		}
+	}
+	return fileRewrite{path, rewritten, count}, nil
+}
+
+// rewriteGoMod rewrites the module directive and the module paths of require and replace directives in a
+// go.mod file, so a dependency moving to a fork is required under its new path too.
+func rewriteGoMod(path, from, to string) (fileRewrite, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileRewrite{}, err
+	}
+	file, err := modfile.Parse(path, content, nil)
+	if err != nil {
+		return fileRewrite{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	count := 0
+	if newPath, ok := replacePathPrefix(file.Module.Mod.Path, from, to); ok {
+		if err := file.AddModuleStmt(newPath); err != nil {
+			return fileRewrite{}, err
+		}
+		count++
+	}
+	for _, require := range file.Require {
+		count += rewriteModPathTokens(require.Syntax, from, to, require.Mod.Path)
+	}
+	for _, replace := range file.Replace {
+		count += rewriteModPathTokens(replace.Syntax, from, to, replace.Old.Path, replace.New.Path)
+	}
+	if count == 0 {
+		return fileRewrite{path: path}, nil
+	}
+
+	rewritten, err := file.Format()
+	if err != nil {
+		return fileRewrite{}, err
+	}
+	return fileRewrite{path, rewritten, count}, nil
+}
+
+// rewriteModPathTokens rewrites the tokens of a go.mod line that are one of its module paths and start with
+// from, returning how many it rewrote. Keywords, versions and arrows are left alone.
+func rewriteModPathTokens(line *modfile.Line, from, to string, paths ...string) int {
+	count := 0
+	for i, tok := range line.Token {
+		unquoted, err := strconv.Unquote(tok)
+		if err != nil {
+			unquoted = tok
+		}
+		isPath := false
+		for _, path := range paths {
+			isPath = isPath || unquoted == path
+		}
+		if !isPath {
+			continue
+		}
+		if newPath, ok := replacePathPrefix(unquoted, from, to); ok {
+			line.Token[i] = modfile.AutoQuote(newPath)
+			count++
+		}
+	}
+	return count
+}
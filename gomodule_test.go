@@ -0,0 +1,194 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func migrate(t *testing.T, root, from, to string) *NameShifter {
+	t.Helper()
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.migrateModulePath([]string{root, from, to}); err != nil {
+		t.Fatal(err)
+	}
+	return ns
+}
+
+func TestMigrateModulePathLeavesPrefixCollisionsAlone(t *testing.T) {
+	// example.com/ab starts with the characters of example.com/a, but not with its path elements.
+	root := writeTree(t, map[string]string{
+		"go.mod":    "module example.com/a\n\ngo 1.21\n\nreplace example.com/ab => ../ab\n",
+		"a.go":      "package a\n\nimport (\n\t\"example.com/a/util\"\n\t\"example.com/ab/other\"\n)\n\nvar _, _ = util.X, other.Y\n",
+		"util/x.go": "package util\n\nconst X = 1\n",
+	})
+
+	ns := migrate(t, root, "example.com/a", "example.com/renamed")
+
+	got := readTree(t, root, "a.go")
+	if !strings.Contains(got, `"example.com/renamed/util"`) || !strings.Contains(got, `"example.com/ab/other"`) {
+		t.Errorf("a.go:\n%s", got)
+	}
+	mod := readTree(t, root, "go.mod")
+	if !strings.HasPrefix(mod, "module example.com/renamed\n") || !strings.Contains(mod, "replace example.com/ab => ../ab") {
+		t.Errorf("go.mod:\n%s", mod)
+	}
+	if ns.Context.replacementsCount != 2 {
+		t.Errorf("replacements = %d, want the import and the module directive", ns.Context.replacementsCount)
+	}
+}
+
+func TestMigrateModulePathRenamesNestedModules(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":             "module example.com/app\n\ngo 1.21\n\nreplace example.com/app/tools => ./tools\n",
+		"main.go":            "package main\n\nimport \"example.com/app/tools/gen\"\n\nfunc main() { gen.Run() }\n",
+		"tools/go.mod":       "module example.com/app/tools\n\ngo 1.21\n",
+		"tools/gen/gen.go":   "package gen\n\nfunc Run() {}\n",
+		"tools/cmd/main.go":  "package main\n\nimport \"example.com/app/tools/gen\"\n\nfunc main() { gen.Run() }\n",
+		"vendor/x/vendor.go": "package x\n\nimport _ \"example.com/app/tools/gen\"\n",
+	})
+
+	migrate(t, root, "example.com/app", "example.org/app")
+
+	for name, want := range map[string]string{
+		"go.mod":             "replace example.org/app/tools => ./tools",
+		"tools/go.mod":       "module example.org/app/tools",
+		"main.go":            `import "example.org/app/tools/gen"`,
+		"tools/cmd/main.go":  `import "example.org/app/tools/gen"`,
+		"vendor/x/vendor.go": `import _ "example.com/app/tools/gen"`, // vendor is skipped, like the go tool does.
+	} {
+		if got := readTree(t, root, name); !strings.Contains(got, want) {
+			t.Errorf("%s doesn't contain %q:\n%s", name, want, got)
+		}
+	}
+}
+
+func TestMigrateModulePathRewritesReplaceDirectives(t *testing.T) {
+	// A dependency moving to a fork: no scanned module owns it, so no directory moves.
+	root := writeTree(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n\nreplace (\n\texample.com/lib v1.0.0 => example.com/lib/v2 v2.0.0\n\texample.com/library => ../library\n)\n",
+		"app.go": "package app\n\nimport lib \"example.com/lib/sub\"\n\nvar _ = lib.X\n",
+	})
+
+	migrate(t, root, "example.com/lib", "github.com/fork/lib")
+
+	mod := readTree(t, root, "go.mod")
+	for _, want := range []string{
+		"github.com/fork/lib v1.0.0 => github.com/fork/lib/v2 v2.0.0",
+		"example.com/library => ../library",
+	} {
+		if !strings.Contains(mod, want) {
+			t.Errorf("go.mod doesn't contain %q:\n%s", want, mod)
+		}
+	}
+	if got := readTree(t, root, "app.go"); !strings.Contains(got, `import lib "github.com/fork/lib/sub"`) {
+		t.Errorf("app.go:\n%s", got)
+	}
+}
+
+func TestMigrateModulePathMovesDirectories(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":                  "module example.com/app\n\ngo 1.21\n",
+		"main.go":                 "package main\n\nimport (\n\t\"example.com/app/internal/old\"\n\t\"example.com/app/internal/old/deep\"\n\t\"example.com/app/internal/older\"\n)\n\nfunc main() { old.F(); deep.F(); older.F() }\n",
+		"internal/old/old.go":     "package old\n\nfunc F() {}\n",
+		"internal/old/deep/d.go":  "package deep\n\nfunc F() {}\n",
+		"internal/older/older.go": "package older\n\nfunc F() {}\n",
+	})
+
+	migrate(t, root, "example.com/app/internal/old", "example.com/app/pkg/new")
+
+	for _, name := range []string{"pkg/new/old.go", "pkg/new/deep/d.go", "internal/older/older.go"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "internal", "old")); !os.IsNotExist(err) {
+		t.Errorf("internal/old is still there: %v", err)
+	}
+	want := "\t\"example.com/app/internal/older\"\n\t\"example.com/app/pkg/new\"\n\t\"example.com/app/pkg/new/deep\"\n"
+	if got := readTree(t, root, "main.go"); !strings.Contains(got, want) {
+		t.Errorf("main.go should have its imports sorted again:\n%s", got)
+	}
+}
+
+func TestMigrateModulePathRefusesBadMoves(t *testing.T) {
+	files := map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.21\n",
+		"old/old.go": "package old\n",
+		"taken/t.go": "package taken\n",
+	}
+	tests := []struct {
+		from, to, want string
+	}{
+		{"example.com/app/old", "example.com/app/taken", "already exists"},
+		{"example.com/app/old", "example.com/other/old", "within its module"},
+		{"example.com/app", "example.com/app/v2", "into itself"},
+		{"example.com/app", "example.com/app", "already the import path"},
+	}
+	for _, tt := range tests {
+		root := writeTree(t, files)
+		ns := NewNameShifter(&Config{}, NewAppContext())
+		err := ns.migrateModulePath([]string{root, tt.from, tt.to})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s → %s: got %v, want an error mentioning %q", tt.from, tt.to, err, tt.want)
+		}
+		if _, err := os.Stat(filepath.Join(root, "old", "old.go")); err != nil {
+			t.Errorf("%s → %s moved files despite failing", tt.from, tt.to)
+		}
+	}
+}
+
+func TestReplacePathPrefix(t *testing.T) {
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"example.com/a", "example.com/b", true},
+		{"example.com/a/x", "example.com/b/x", true},
+		{"example.com/ab", "example.com/ab", false},
+		{"example.com", "example.com", false},
+	}
+	for _, tt := range tests {
+		if got, ok := replacePathPrefix(tt.path, "example.com/a", "example.com/b"); got != tt.want || ok != tt.ok {
+			t.Errorf("replacePathPrefix(%q) = %q, %v", tt.path, got, ok)
+		}
+	}
+}
+
+func TestMigrateModulePathRewritesRequireDirectives(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\texample.com/lib v1.2.0\n\texample.com/library v0.1.0\n)\n",
+		"app.go": "package app\n\nimport \"example.com/lib\"\n\nvar _ = lib.X\n",
+	})
+
+	migrate(t, root, "example.com/lib", "github.com/fork/lib")
+
+	mod := readTree(t, root, "go.mod")
+	if !strings.Contains(mod, "\tgithub.com/fork/lib v1.2.0\n") || !strings.Contains(mod, "\texample.com/library v0.1.0\n") {
+		t.Errorf("go.mod:\n%s", mod)
+	}
+}
+
+func TestMigrateModulePathWritesNothingWhenAFileFails(t *testing.T) {
+	files := map[string]string{
+		"go.mod":    "module example.com/app\n\ngo 1.21\n",
+		"main.go":   "package main\n\nimport \"example.com/app/util\"\n\nfunc main() { util.F() }\n",
+		"util/u.go": "package util\n\nfunc F() {}\n",
+		"broken.go": "package main\n\nimport \"example.com/app/util\n",
+	}
+	root := writeTree(t, files)
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.migrateModulePath([]string{root, "example.com/app", "example.org/app"}); err == nil {
+		t.Fatal("migrateModulePath succeeded with a file it can't parse")
+	}
+	for name, want := range files {
+		if got := readTree(t, root, name); got != want {
+			t.Errorf("%s was changed:\n%s", name, got)
+		}
+	}
+	if ns.Context.errorsCount != 1 || ns.Context.replacementsCount != 0 {
+		t.Errorf("errors = %d, replacements = %d, want 1 and 0", ns.Context.errorsCount, ns.Context.replacementsCount)
+	}
+}
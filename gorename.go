@@ -0,0 +1,574 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goRenamer renames one Go object, and the objects that have to be renamed with it, semantically:
+// only identifiers the type checker resolves to those objects are touched. Objects are recognised by
+// their declaring position, since a package and its test variant hold separate objects for the same
+// declaration.
+type goRenamer struct {
+	prog     *goProgram
+	from, to string
+	targets  map[token.Pos]types.Object
+}
+
+// positionPattern matches targets such as "internal/store/user.go:12:6".
+var positionPattern = regexp.MustCompile(`^(.+\.go):(\d+):(\d+)$`)
+
+// goRename implements the go-rename subcommand: go-rename <startingDir> <target> <newName>, where the
+// target is a file:line:column position or a qualified name such as example.com/app/store.User.Save.
+func (ns *NameShifter) goRename(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: nsh go-rename <startingDirectory> <file.go:line:column | importPath.Name[.Member]> <newName>")
+	}
+	startingDir, target, newName := args[0], args[1], args[2]
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return fmt.Errorf("%q is not a valid Go identifier", newName)
+	}
+
+	prog, err := loadGoProgram(startingDir)
+	if err != nil {
+		return err
+	}
+	if errs := prog.typeErrors(); len(errs) > 0 {
+		return fmt.Errorf("refusing to rename in code that doesn't type-check (%d errors), first: %v", len(errs), errs[0])
+	}
+
+	obj, err := prog.lookupObject(target)
+	if err != nil {
+		return err
+	}
+	if obj.Name() == newName {
+		return fmt.Errorf("%s is already named %s", target, newName)
+	}
+
+	r := &goRenamer{prog: prog, from: obj.Name(), to: newName, targets: make(map[token.Pos]types.Object)}
+	if err := r.addTarget(obj); err != nil {
+		return err
+	}
+	r.addEmbeddedFields()
+	if err := r.checkConflicts(); err != nil {
+		return err
+	}
+	return ns.applyGoRename(r.edits(), r.from, r.to)
+}
+
+// lookupObject resolves a file:line:column position or a qualified name to the object it denotes.
+func (prog *goProgram) lookupObject(target string) (types.Object, error) {
+	if match := positionPattern.FindStringSubmatch(target); match != nil {
+		filename, err := filepath.Abs(match[1])
+		if err != nil {
+			return nil, err
+		}
+		line, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		return prog.objectAt(filename, line, column)
+	}
+	return prog.objectNamed(strings.Trim(target, `"`))
+}
+
+// objectAt returns the object denoted by the identifier at the given line and column (in bytes, from 1).
+func (prog *goProgram) objectAt(filename string, line, column int) (types.Object, error) {
+	var file *token.File
+	prog.fset.Iterate(func(f *token.File) bool {
+		if f.Name() == filename {
+			file = f
+		}
+		return file == nil
+	})
+	if file == nil {
+		return nil, fmt.Errorf("%s isn't part of any package under the starting directory", filename)
+	}
+	if line < 1 || line > file.LineCount() {
+		return nil, fmt.Errorf("%s has no line %d", filename, line)
+	}
+	offset := file.Offset(file.LineStart(line)) + column - 1
+	if column < 1 || offset > file.Size() {
+		return nil, fmt.Errorf("%s:%d has no column %d", filename, line, column)
+	}
+	pos := file.Pos(offset)
+
+	for _, pkg := range prog.roots {
+		for _, idents := range []map[*ast.Ident]types.Object{pkg.info.Defs, pkg.info.Uses} {
+			for ident, obj := range idents {
+				if ident.Pos() > pos || pos >= ident.End() {
+					continue
+				}
+				if obj == nil {
+					obj = implicitObject(pkg.info, ident) // The variable of a type switch.
+				}
+				if obj != nil {
+					return obj, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no identifier at %s:%d:%d", filename, line, column)
+}
+
+// implicitObject returns an object declared implicitly by ident, such as the per-clause variables
+// of "switch v := x.(type)".
+func implicitObject(info *types.Info, ident *ast.Ident) types.Object {
+	for _, obj := range info.Implicits {
+		if obj.Pos() == ident.Pos() {
+			return obj
+		}
+	}
+	return nil
+}
+
+// objectNamed resolves importPath.Name or importPath.Type.Member.
+func (prog *goProgram) objectNamed(name string) (types.Object, error) {
+	var pkgs []*goPackage
+	var rest string
+	for _, pkg := range prog.packages() {
+		suffix, ok := strings.CutPrefix(name, pkg.path+".")
+		if !ok {
+			continue
+		}
+		if len(pkgs) > 0 && len(pkg.path) < len(pkgs[0].path) {
+			continue
+		}
+		if len(pkgs) > 0 && len(pkg.path) > len(pkgs[0].path) {
+			pkgs = nil
+		}
+		pkgs, rest = append(pkgs, pkg), suffix
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("%s doesn't name anything in a package under the starting directory", name)
+	}
+
+	typeName, member, isMember := strings.Cut(rest, ".")
+	for _, pkg := range pkgs {
+		obj := pkg.types.Scope().Lookup(typeName)
+		if obj == nil {
+			continue // Maybe it's declared in a _test.go file, see the test variant.
+		}
+		if !isMember {
+			return obj, nil
+		}
+		if _, ok := obj.(*types.TypeName); !ok {
+			return nil, fmt.Errorf("%s.%s isn't a type", pkg.path, typeName)
+		}
+		if found, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg.types, member); found != nil {
+			return found, nil
+		}
+		return nil, fmt.Errorf("%s.%s has no field or method %s", pkg.path, typeName, member)
+	}
+	return nil, fmt.Errorf("%s doesn't name anything in a package under the starting directory", name)
+}
+
+// addTarget adds obj, and whatever has to be renamed along with it, to the objects to rename.
+func (r *goRenamer) addTarget(obj types.Object) error {
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return fmt.Errorf("%s is an imported package name, go-rename only renames declared objects", obj.Name())
+	case *types.Var:
+		if obj.Embedded() {
+			// An embedded field is named after its type, so renaming it means renaming the type.
+			if named, ok := derefType(obj.Type()).(*types.Named); ok {
+				return r.addTarget(named.Obj())
+			}
+		}
+	}
+	if obj.Pkg() == nil {
+		return fmt.Errorf("%s is predeclared and can't be renamed", obj.Name())
+	}
+	if !r.prog.underRoot(obj.Pos()) {
+		return fmt.Errorf("%s is declared at %s, outside the starting directory", obj.Name(), r.prog.fset.PositionFor(obj.Pos(), false))
+	}
+	if _, ok := r.targets[obj.Pos()]; ok {
+		return nil
+	}
+	r.targets[obj.Pos()] = obj
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	if iface, ok := recv.Type().Underlying().(*types.Interface); ok {
+		// Renaming an interface method renames it in every type implementing the interface, so they keep doing so.
+		for _, named := range r.prog.namedTypes() {
+			if types.IsInterface(named) {
+				continue
+			}
+			for _, typ := range []types.Type{named, types.NewPointer(named)} {
+				if !types.Implements(typ, iface) {
+					continue
+				}
+				if method, _, _ := types.LookupFieldOrMethod(typ, true, fn.Pkg(), r.from); method != nil {
+					if err := r.addTarget(method); err != nil {
+						return err
+					}
+				}
+				break
+			}
+		}
+		return nil
+	}
+	for _, named := range r.prog.namedTypes() {
+		iface, ok := named.Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		if method, _, _ := types.LookupFieldOrMethod(iface, false, fn.Pkg(), r.from); method == nil || r.isTarget(method) {
+			continue
+		}
+		if types.Implements(recv.Type(), iface) {
+			return fmt.Errorf("renaming %s would stop %s implementing %s, rename the interface method %s.%s instead",
+				r.from, types.TypeString(recv.Type(), nil), named.Obj().Name(), named.Obj().Name(), r.from)
+		}
+	}
+	return nil
+}
+
+// addEmbeddedFields adds the fields embedding a renamed type, since they're named after it.
+func (r *goRenamer) addEmbeddedFields() {
+	for _, pkg := range r.prog.packages() {
+		for ident, obj := range pkg.info.Defs {
+			if field, ok := obj.(*types.Var); ok && field.Embedded() && r.isTarget(pkg.info.Uses[ident]) {
+				r.targets[field.Pos()] = field
+			}
+		}
+	}
+}
+
+// isTarget reports whether obj is one of the objects being renamed.
+func (r *goRenamer) isTarget(obj types.Object) bool {
+	if obj == nil || obj.Name() != r.from {
+		return false
+	}
+	_, ok := r.targets[obj.Pos()]
+	return ok
+}
+
+// derefType strips a pointer off typ.
+func derefType(typ types.Type) types.Type {
+	if pointer, ok := typ.(*types.Pointer); ok {
+		return pointer.Elem()
+	}
+	return typ
+}
+
+// namedTypes returns every non-generic named type declared in the loaded packages.
+func (prog *goProgram) namedTypes() []*types.Named {
+	var named []*types.Named
+	seen := make(map[token.Pos]bool)
+	for _, pkg := range prog.packages() {
+		for _, obj := range pkg.info.Defs {
+			typeName, ok := obj.(*types.TypeName)
+			if !ok || typeName.IsAlias() || seen[typeName.Pos()] {
+				continue
+			}
+			if n, ok := typeName.Type().(*types.Named); ok && n.TypeParams().Len() == 0 {
+				seen[typeName.Pos()] = true
+				named = append(named, n)
+			}
+		}
+	}
+	return named
+}
+
+// checkConflicts refuses renames that wouldn't compile or would quietly change what some identifier
+// refers to: collisions with a declaration in the same scope or type, shadowing at a reference, and
+// capturing references to another object of the new name.
+func (r *goRenamer) checkConflicts() error {
+	for _, obj := range r.targets {
+		if obj.Exported() && !token.IsExported(r.to) {
+			for _, pkg := range r.prog.packages() {
+				if pkg.types.Path() == obj.Pkg().Path() {
+					continue
+				}
+				for ident, used := range pkg.info.Uses {
+					if r.isTarget(used) {
+						return fmt.Errorf("%s is used from package %s at %s and %s wouldn't be exported",
+							r.from, pkg.path, r.prog.fset.PositionFor(ident.Pos(), false), r.to)
+					}
+				}
+			}
+		}
+
+		var err error
+		switch obj := obj.(type) {
+		case *types.Var:
+			if obj.IsField() {
+				err = r.checkMemberConflicts(obj)
+			} else {
+				err = r.checkScopeConflicts(obj)
+			}
+		case *types.Func:
+			if obj.Type().(*types.Signature).Recv() != nil {
+				err = r.checkMemberConflicts(obj)
+			} else {
+				err = r.checkScopeConflicts(obj)
+			}
+		case *types.Label:
+			err = r.checkLabelConflicts(obj)
+		default:
+			err = r.checkScopeConflicts(obj)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMemberConflicts refuses renaming a field or method when a type it belongs to, directly or
+// through embedding, already has a field or method of the new name.
+func (r *goRenamer) checkMemberConflicts(obj types.Object) error {
+	seen := make(map[types.Type]bool)
+	check := func(typ types.Type) error {
+		if typ == nil || seen[typ] {
+			return nil
+		}
+		seen[typ] = true
+		if found, _, _ := types.LookupFieldOrMethod(typ, true, obj.Pkg(), r.from); !r.isTarget(found) {
+			return nil
+		}
+		if clash, _, _ := types.LookupFieldOrMethod(typ, true, obj.Pkg(), r.to); clash != nil {
+			return fmt.Errorf("%s already has %s at %s", types.TypeString(typ, nil), r.to, r.prog.fset.PositionFor(clash.Pos(), false))
+		}
+		return nil
+	}
+
+	for _, named := range r.prog.namedTypes() {
+		for _, typ := range []types.Type{named, types.NewPointer(named)} {
+			if err := check(typ); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pkg := range r.prog.packages() {
+		for _, tv := range pkg.info.Types {
+			if tv.Type == nil {
+				continue
+			}
+			if _, ok := derefType(tv.Type).Underlying().(*types.Struct); ok { // Anonymous structs, instantiated generics.
+				if err := check(tv.Type); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkScopeConflicts refuses renaming an object declared in a scope when the new name collides with
+// another declaration there, is shadowed at one of its references, or would capture references to an
+// outer object of the new name.
+func (r *goRenamer) checkScopeConflicts(obj types.Object) error {
+	if obj.Parent() == obj.Pkg().Scope() && r.to == "init" {
+		return errors.New("package level objects can't be named init")
+	}
+
+	for _, pkg := range r.prog.packages() {
+		if pkg.types.Path() != obj.Pkg().Path() {
+			continue // Other packages can only refer to it qualified, as pkg.Name.
+		}
+		declScope := declaringScope(pkg, obj)
+		if declScope == nil {
+			continue // Declared in a _test.go file this variant doesn't have.
+		}
+		if clash := declScope.Lookup(r.to); clash != nil {
+			return fmt.Errorf("%s collides with %s declared at %s", r.to, clash.Name(), r.prog.fset.PositionFor(clash.Pos(), false))
+		}
+		if declScope == pkg.types.Scope() {
+			for _, file := range pkg.files {
+				if clash := pkg.info.Scopes[file].Lookup(r.to); clash != nil {
+					return fmt.Errorf("%s collides with the import at %s", r.to, r.prog.fset.PositionFor(clash.Pos(), false))
+				}
+			}
+		}
+
+		for ident, used := range pkg.info.Uses {
+			if r.isTarget(used) {
+				// After the rename this reference has to find obj, not something of the new name in between.
+				scope := pkg.types.Scope().Innermost(ident.Pos())
+				if scope == nil {
+					continue
+				}
+				if _, clash := scope.LookupParent(r.to, ident.Pos()); clash != nil && !isAncestorScope(clash.Parent(), declScope) {
+					return fmt.Errorf("%s at %s would be shadowed by %s declared at %s",
+						r.from, r.prog.fset.PositionFor(ident.Pos(), false), r.to, r.prog.fset.PositionFor(clash.Pos(), false))
+				}
+				continue
+			}
+			if used.Name() != r.to || used.Parent() == nil {
+				continue // Fields and methods are always selected, so obj can't capture them.
+			}
+			if !isAncestorScope(used.Parent(), declScope) || !withinScope(pkg, declScope, obj, ident.Pos()) {
+				continue
+			}
+			return fmt.Errorf("renaming %s would capture the reference to %s at %s",
+				r.from, r.to, r.prog.fset.PositionFor(ident.Pos(), false))
+		}
+	}
+	return nil
+}
+
+// checkLabelConflicts refuses renaming a label to the name of another label of the same function.
+func (r *goRenamer) checkLabelConflicts(label *types.Label) error {
+	for _, pkg := range r.prog.packages() {
+		for _, obj := range pkg.info.Defs {
+			clash, ok := obj.(*types.Label)
+			if ok && clash.Name() == r.to && enclosingFunc(pkg, clash.Pos()) == enclosingFunc(pkg, label.Pos()) {
+				return fmt.Errorf("%s collides with the label at %s", r.to, r.prog.fset.PositionFor(clash.Pos(), false))
+			}
+		}
+	}
+	return nil
+}
+
+// enclosingFunc returns the outermost function declaration or literal containing pos.
+func enclosingFunc(pkg *goPackage, pos token.Pos) ast.Node {
+	for _, file := range pkg.files {
+		if file.Pos() > pos || pos > file.End() {
+			continue
+		}
+		var found ast.Node
+		ast.Inspect(file, func(node ast.Node) bool {
+			if found != nil || node == nil || node.Pos() > pos || pos > node.End() {
+				return false
+			}
+			switch node.(type) {
+			case *ast.FuncDecl, *ast.FuncLit:
+				found = node
+				return false
+			}
+			return true
+		})
+		return found
+	}
+	return nil
+}
+
+// declaringScope finds the scope of pkg that declares obj, or nil when pkg doesn't contain its declaration.
+func declaringScope(pkg *goPackage, obj types.Object) *types.Scope {
+	if found := pkg.types.Scope().Lookup(obj.Name()); found != nil && found.Pos() == obj.Pos() {
+		return pkg.types.Scope()
+	}
+	for scope := pkg.types.Scope().Innermost(obj.Pos()); scope != nil; scope = scope.Parent() {
+		if found := scope.Lookup(obj.Name()); found != nil && found.Pos() == obj.Pos() {
+			return scope
+		}
+	}
+	return nil
+}
+
+// isAncestorScope reports whether outer strictly encloses inner.
+func isAncestorScope(outer, inner *types.Scope) bool {
+	for scope := inner.Parent(); scope != nil; scope = scope.Parent() {
+		if scope == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// withinScope reports whether an identifier at pos would see obj, declared in scope, once renamed.
+func withinScope(pkg *goPackage, scope *types.Scope, obj types.Object, pos token.Pos) bool {
+	if scope == pkg.types.Scope() {
+		return true // Package level declarations are visible in every file of the package.
+	}
+	return scope.Contains(pos) && pos > obj.Pos()
+}
+
+// goEdit is one identifier to rename, by file and byte offset.
+type goEdit struct {
+	filename string
+	offset   int
+}
+
+// edits lists every identifier below the starting directory that refers to, or declares, a target.
+func (r *goRenamer) edits() []goEdit {
+	positions := make(map[token.Pos]bool)
+	for pos := range r.targets {
+		positions[pos] = true
+	}
+	for _, pkg := range r.prog.packages() {
+		for _, idents := range []map[*ast.Ident]types.Object{pkg.info.Defs, pkg.info.Uses} {
+			for ident, obj := range idents {
+				if r.isTarget(obj) {
+					positions[ident.Pos()] = true
+				}
+			}
+		}
+	}
+
+	var edits []goEdit
+	for pos := range positions {
+		if !r.prog.underRoot(pos) {
+			continue
+		}
+		position := r.prog.fset.PositionFor(pos, false)
+		edits = append(edits, goEdit{position.Filename, position.Offset})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].filename != edits[j].filename {
+			return edits[i].filename < edits[j].filename
+		}
+		return edits[i].offset > edits[j].offset // Back to front, so earlier offsets stay valid.
+	})
+	return edits
+}
+
+// applyGoRename rewrites the identifiers at edits from one name to the other and gofmts the files. Every
+// file's new content is worked out before any is written, so a file that changed in the meantime leaves
+// them all untouched rather than the packages half renamed.
+func (ns *NameShifter) applyGoRename(edits []goEdit, from, to string) error {
+	var filenames []string
+	contents := make(map[string][]byte)
+	for start := 0; start < len(edits); {
+		filename := edits[start].filename
+		end := start
+		for end < len(edits) && edits[end].filename == filename {
+			end++
+		}
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		for _, edit := range edits[start:end] {
+			if !strings.HasPrefix(string(content[edit.offset:]), from) {
+				return fmt.Errorf("%s changed while renaming, expected %s at offset %d", filename, from, edit.offset)
+			}
+			content = append(content[:edit.offset], append([]byte(to), content[edit.offset+len(from):]...)...)
+		}
+		// A name of another length shifts the alignment of fields, comments and values after it.
+		if content, err = format.Source(content); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		filenames = append(filenames, filename)
+		contents[filename] = content
+		start = end
+	}
+
+	for _, filename := range filenames {
+		if err := ns.writeFileContent(filename, contents[filename]); err != nil {
+			return err
+		}
+	}
+	for range edits {
+		ns.Context.AddReplacement()
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates the files, given by slash-separated paths relative to a new temporary
+// directory, and returns that directory.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// readTree returns the content of a file written by writeTree.
+func readTree(t *testing.T, root, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+const storeModule = "module example.com/app\n\ngo 1.21\n"
+
+func TestGoRenameMethodAcrossPackages(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":         storeModule,
+		"store/user.go":  "package store\n\ntype User struct{ Name string }\n\nfunc (u User) Save() string { return \"Save\" }\n",
+		"store/other.go": "package store\n\nfunc Save() {}\n",
+		"cmd/main.go":    "package main\n\nimport \"example.com/app/store\"\n\nfunc main() {\n\tu := store.User{}\n\t_ = u.Save()\n\tstore.Save()\n}\n",
+	})
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.goRename([]string{root, "example.com/app/store.User.Save", "Persist"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTree(t, root, "store/user.go"); !strings.Contains(got, "func (u User) Persist() string { return \"Save\" }") {
+		t.Errorf("store/user.go:\n%s", got)
+	}
+	if got := readTree(t, root, "cmd/main.go"); !strings.Contains(got, "_ = u.Persist()\n\tstore.Save()") {
+		t.Errorf("cmd/main.go should rename the method call only:\n%s", got)
+	}
+	if got := readTree(t, root, "store/other.go"); !strings.Contains(got, "func Save()") {
+		t.Errorf("the unrelated Save function was renamed:\n%s", got)
+	}
+	if ns.Context.replacementsCount != 2 {
+		t.Errorf("replacements = %d, want 2", ns.Context.replacementsCount)
+	}
+}
+
+func TestGoRenameEmbeddedType(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":     storeModule,
+		"model.go":   "package app\n\ntype Base struct{ ID int }\n\ntype User struct {\n\tBase\n\tName string\n}\n",
+		"service.go": "package app\n\nfunc id(u User) int { return u.Base.ID + u.ID }\n",
+	})
+
+	// Renaming the embedded field renames its type, and every selector naming the field.
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.goRename([]string{root, filepath.Join(root, "model.go") + ":6:2", "Entity"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTree(t, root, "model.go"); !strings.Contains(got, "type Entity struct") || !strings.Contains(got, "\tEntity\n") {
+		t.Errorf("model.go:\n%s", got)
+	}
+	if got := readTree(t, root, "service.go"); !strings.Contains(got, "u.Entity.ID + u.ID") {
+		t.Errorf("service.go:\n%s", got)
+	}
+}
+
+func TestGoRenameRefusesConflicts(t *testing.T) {
+	files := map[string]string{
+		"go.mod":   storeModule,
+		"model.go": "package app\n\ntype User struct{ Name string }\n\nfunc (u User) Label() string { return u.Name }\n\nfunc helper() {}\n\nfunc other() {}\n\nfunc shadow() {\n\tother := 1\n\t_ = other\n\thelper()\n}\n",
+	}
+	tests := []struct {
+		target, newName, want string
+	}{
+		{"example.com/app.helper", "other", "other"},    // another package level declaration.
+		{"example.com/app.User.Name", "Label", "Label"}, // a method of the same type.
+		{"example.com/app.helper", "_", "valid Go identifier"},
+		{"example.com/app.helper", "helper", "already named"},
+	}
+	for _, tt := range tests {
+		root := writeTree(t, files)
+		ns := NewNameShifter(&Config{}, NewAppContext())
+		err := ns.goRename([]string{root, tt.target, tt.newName})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("renaming %s to %s: got %v, want an error mentioning %q", tt.target, tt.newName, err, tt.want)
+		}
+		if got := readTree(t, root, "model.go"); got != files["model.go"] {
+			t.Errorf("renaming %s to %s changed the code despite failing", tt.target, tt.newName)
+		}
+	}
+}
+
+func TestGoRenameRefusesShadowedReference(t *testing.T) {
+	// helper is called where a local variable named other is in scope.
+	root := writeTree(t, map[string]string{
+		"go.mod": storeModule,
+		"app.go": "package app\n\nfunc helper() {}\n\nfunc run() {\n\tother := 1\n\t_ = other\n\thelper()\n}\n",
+	})
+	ns := NewNameShifter(&Config{}, NewAppContext())
+
+	if err := ns.goRename([]string{root, "example.com/app.helper", "other"}); err == nil {
+		t.Error("expected renaming helper to a name shadowed at its call to fail")
+	}
+}
+
+func TestGoRenameUpdatesTestPackages(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":                  storeModule,
+		"store/store.go":          "package store\n\nfunc Open() int { return 1 }\n",
+		"store/internal_test.go":  "package store\n\nvar opened = Open()\n",
+		"store/external_test.go":  "package store_test\n\nimport \"example.com/app/store\"\n\nvar opened = store.Open()\n",
+		"store/testdata/stale.go": "package stale\n\nfunc Open() {}\n",
+	})
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.goRename([]string{root, "example.com/app/store.Open", "Connect"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"store/store.go":          "func Connect() int",
+		"store/internal_test.go":  "var opened = Connect()",
+		"store/external_test.go":  "var opened = store.Connect()",
+		"store/testdata/stale.go": "func Open() {}",
+	} {
+		if got := readTree(t, root, name); !strings.Contains(got, want) {
+			t.Errorf("%s doesn't contain %q:\n%s", name, want, got)
+		}
+	}
+}
+
+func TestGoRenameRefusesCodeThatDoesntTypeCheck(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod": storeModule,
+		"app.go": "package app\n\nfunc helper() int { return \"x\" }\n",
+	})
+	ns := NewNameShifter(&Config{}, NewAppContext())
+
+	err := ns.goRename([]string{root, "example.com/app.helper", "assist"})
+	if err == nil || !strings.Contains(err.Error(), "doesn't type-check") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestGoRenameIgnoresLineDirectives(t *testing.T) {
+	// Generated code points its positions at the template it came from; the edits still go to the Go file.
+	root := writeTree(t, map[string]string{
+		"go.mod": storeModule,
+		"gen.go": "package app\n\n//line templates/model.tmpl:10\ntype Record struct{}\n\nvar _ Record\n",
+	})
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.goRename([]string{root, "example.com/app.Record", "Row"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTree(t, root, "gen.go"); !strings.Contains(got, "type Row struct{}\n\nvar _ Row\n") {
+		t.Errorf("gen.go:\n%s", got)
+	}
+}
+
+func TestApplyGoRenameWritesNothingWhenAFileChanged(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.go": "package app\n\nvar Old = 1\n",
+		"b.go": "package app\n\nvar _ = Odd\n",
+	})
+	edits := []goEdit{
+		{filepath.Join(root, "a.go"), len("package app\n\nvar ")},
+		{filepath.Join(root, "b.go"), len("package app\n\nvar _ = ")},
+	}
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.applyGoRename(edits, "Old", "New"); err == nil || !strings.Contains(err.Error(), "changed while renaming") {
+		t.Fatalf("applyGoRename = %v, want an error about b.go", err)
+	}
+	if got := readTree(t, root, "a.go"); got != "package app\n\nvar Old = 1\n" {
+		t.Errorf("a.go was written although b.go couldn't be:\n%s", got)
+	}
+	if ns.Context.replacementsCount != 0 {
+		t.Errorf("replacements = %d, want 0", ns.Context.replacementsCount)
+	}
+}
+
+func TestGoRenameRealignsDeclarations(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"go.mod":   storeModule,
+		"model.go": "package app\n\ntype User struct {\n\tID   int    // key\n\tName string // shown\n}\n",
+	})
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	if err := ns.goRename([]string{root, "example.com/app.User.ID", "Identifier"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "package app\n\ntype User struct {\n\tIdentifier int    // key\n\tName       string // shown\n}\n"
+	if got := readTree(t, root, "model.go"); got != want {
+		t.Errorf("model.go:\n%s\nwant:\n%s", got, want)
+	}
+}
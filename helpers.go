@@ -7,6 +7,7 @@ import (
 	"github.com/jedib0t/go-pretty/v6/text"
 	"math/rand"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,12 +17,15 @@ type AppContext struct {
 	errorsCount       int32
 	replacementsCount int32
 	errorReport       table.Writer
-	mutex             sync.Mutex // Protects errorReport updates.
+	ruleCounts        map[*Rule]int
+	patches           []bytePatch
+	mutex             sync.Mutex // Protects errorReport, ruleCounts and patches updates.
 }
 
 func NewAppContext() *AppContext {
 	return &AppContext{
 		errorReport: table.NewWriter(),
+		ruleCounts:  make(map[*Rule]int),
 	}
 }
 
@@ -33,6 +37,21 @@ func (ctx *AppContext) AddReplacement() {
 	atomic.AddInt32(&ctx.replacementsCount, 1)
 }
 
+// AddRuleReplacements records count replacements made by rule, counting them towards the overall total too.
+func (ctx *AppContext) AddRuleReplacements(rule *Rule, count int) {
+	atomic.AddInt32(&ctx.replacementsCount, int32(count))
+	ctx.mutex.Lock()
+	ctx.ruleCounts[rule] += count
+	ctx.mutex.Unlock()
+}
+
+// AddPatches records the patches made to a file processed with -binary.
+func (ctx *AppContext) AddPatches(patches []bytePatch) {
+	ctx.mutex.Lock()
+	ctx.patches = append(ctx.patches, patches...)
+	ctx.mutex.Unlock()
+}
+
 func (ctx *AppContext) AddErrorReportRow(row []table.Row) {
 	ctx.mutex.Lock()
 	ctx.errorReport.AppendRows(row)
@@ -69,6 +88,102 @@ func (ctx *AppContext) ReplacementsAndErrorsReport() {
 	resetColors() // Assuming resetColors is a function that resets terminal color settings.
 }
 
+// RuleReport renders how many replacements each rule made, in the order the rules were applied.
+func (ctx *AppContext) RuleReport(rules []*Rule) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{"#", "Rule", "Replacements"}
+	t.AppendHeader(header)
+	ctx.mutex.Lock()
+	for i, rule := range rules {
+		t.AppendRow(table.Row{i + 1, rule.Name, ctx.ruleCounts[rule]})
+	}
+	ctx.mutex.Unlock()
+	t.AppendSeparator()
+	t.AppendFooter(table.Row{">", "Rules", len(rules)})
+
+	t = formatColumn(t, header)
+	t.SetStyle(table.StyleColoredBlackOnCyanWhite)
+	t.Render()
+	fmt.Println("")
+	resetColors()
+}
+
+// MovedPackagesReport renders the packages a module path migration gave a new import path.
+func (ctx *AppContext) MovedPackagesReport(moves []packageMove) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{"#", "Package", "Moved To"}
+	t.AppendHeader(header)
+	for i, move := range moves {
+		t.AppendRow(table.Row{i + 1, move.from, move.to})
+	}
+	t.AppendSeparator()
+	t.AppendFooter(table.Row{">", "Packages", len(moves)})
+
+	t = formatColumn(t, header)
+	t.SetStyle(table.StyleColoredBlackOnCyanWhite)
+	t.Render()
+	fmt.Println("")
+	resetColors()
+}
+
+// PatchReport renders the patches -binary made, by file and byte offset.
+func (ctx *AppContext) PatchReport() {
+	ctx.mutex.Lock()
+	patches := append([]bytePatch{}, ctx.patches...)
+	ctx.mutex.Unlock()
+	sort.SliceStable(patches, func(i, j int) bool {
+		if patches[i].path != patches[j].path {
+			return patches[i].path < patches[j].path
+		}
+		return patches[i].offset < patches[j].offset
+	})
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{"#", "File", "Offset", "Original", "Patched", "Length"}
+	t.AppendHeader(header)
+	for i, patch := range patches {
+		length := fmt.Sprintf("%d → %d", len(patch.original), len(patch.replacement))
+		if patch.fitted != "" {
+			length += " (" + patch.fitted + ")"
+		}
+		t.AppendRow(table.Row{i + 1, patch.path, fmt.Sprintf("0x%08x", patch.offset), displayString(patch.original), displayString(patch.replacement), length})
+	}
+	t.AppendSeparator()
+	t.AppendFooter(table.Row{">", "Patches", len(patches)})
+
+	t = formatColumn(t, header)
+	t.SetStyle(table.StyleColoredBlackOnBlueWhite)
+	t.Render()
+	fmt.Println("")
+	resetColors()
+}
+
+// NearMissReport renders the near misses -fuzzy found, with where each of them occurs.
+func (ctx *AppContext) NearMissReport(misses []*nearMiss) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	header := table.Row{"#", "Near Miss", "Of", "Edits", "Found At"}
+	t.AppendHeader(header)
+	for i, miss := range misses {
+		locations := miss.locations
+		if len(locations) > 3 {
+			locations = append(locations[:3:3], fmt.Sprintf("… %d more", len(miss.locations)-3))
+		}
+		t.AppendRow(table.Row{i + 1, displayString(miss.variant), displayString(miss.rule.Search), miss.distance, strings.Join(locations, "\n")})
+	}
+	t.AppendSeparator()
+	t.AppendFooter(table.Row{">", "Near Misses", len(misses)})
+
+	t = formatColumn(t, header)
+	t.SetStyle(table.StyleColoredBlackOnMagentaWhite)
+	t.Render()
+	fmt.Println("")
+	resetColors()
+}
+
 func resetColors() {
 	reset := color.New(color.Reset).SprintFunc()
 	fmt.Printf(reset(""))
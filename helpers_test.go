@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// replaceAll runs a single search/replacement pair, with its options taken from the shifter's
+// configuration, over text.
+func replaceAll(ns *NameShifter, text, search, replacement string) string {
+	return ns.replaceString(text, ns.newRule(search, replacement), newFileState(""))
+}
+
+// shiftFile writes content to a file with the given name in a temporary directory, runs the rules
+// over it with processFile and returns what the file holds afterwards.
+func shiftFile(t *testing.T, ns *NameShifter, name, content string, rules ...*Rule) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.processFile(path, rules); err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(rewritten)
+}
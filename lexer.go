@@ -0,0 +1,191 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// quoteRule describes one kind of string literal in a lexer profile.
+type quoteRule struct {
+	open, close string
+	escapes     bool // A backslash escapes the next character.
+	multiline   bool // The literal may span lines; otherwise an unclosed literal ends with its line.
+}
+
+// lexerProfile is a rough description of a language's comments and strings, good enough to tell
+// comments, string literals and code apart without a full parser.
+type lexerProfile struct {
+	lineComments  []string
+	blockComments [][2]string
+	quotes        []quoteRule
+	// commentAfterSpace only starts line comments at the beginning of a line or after whitespace,
+	// so shell's ${#var} and YAML's url#anchor aren't mistaken for comments.
+	commentAfterSpace bool
+}
+
+var (
+	doubleQuoted = quoteRule{open: `"`, close: `"`, escapes: true}
+	singleQuoted = quoteRule{open: `'`, close: `'`, escapes: true}
+
+	cFamilyProfile = &lexerProfile{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []quoteRule{doubleQuoted, singleQuoted, {open: "`", close: "`", escapes: true, multiline: true}},
+	}
+	rustProfile = &lexerProfile{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []quoteRule{doubleQuoted}, // Single quotes are mostly lifetimes, not literals.
+	}
+	cssProfile = &lexerProfile{
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []quoteRule{doubleQuoted, singleQuoted},
+	}
+	hashProfile = &lexerProfile{
+		lineComments: []string{"#"},
+		// Single quotes are literal in shells, YAML and TOML, so '\' is a whole string.
+		quotes:            []quoteRule{doubleQuoted, {open: `'`, close: `'`}},
+		commentAfterSpace: true,
+	}
+	scriptProfile = &lexerProfile{
+		lineComments:      []string{"#"},
+		quotes:            []quoteRule{doubleQuoted, singleQuoted},
+		commentAfterSpace: true,
+	}
+	pythonProfile = &lexerProfile{
+		lineComments: []string{"#"},
+		quotes: []quoteRule{
+			{open: `"""`, close: `"""`, escapes: true, multiline: true},
+			{open: `'''`, close: `'''`, escapes: true, multiline: true},
+			doubleQuoted, singleQuoted,
+		},
+	}
+	iniProfile = &lexerProfile{
+		lineComments:      []string{";", "#"},
+		quotes:            []quoteRule{doubleQuoted},
+		commentAfterSpace: true,
+	}
+	sqlProfile = &lexerProfile{
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []quoteRule{{open: `'`, close: `'`, multiline: true}, {open: `"`, close: `"`, multiline: true}},
+	}
+	luaProfile = &lexerProfile{
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"--[[", "]]"}},
+		quotes:        []quoteRule{doubleQuoted, singleQuoted, {open: "[[", close: "]]", multiline: true}},
+	}
+	markupProfile = &lexerProfile{
+		blockComments: [][2]string{{"<!--", "-->"}},
+		// Quotes in prose are apostrophes far more often than literals, so markup has none.
+	}
+)
+
+// lexerProfiles maps file extensions to the profile used to scope them.
+var lexerProfiles = map[string]*lexerProfile{
+	".c": cFamilyProfile, ".h": cFamilyProfile, ".cc": cFamilyProfile, ".cpp": cFamilyProfile, ".hpp": cFamilyProfile,
+	".cs": cFamilyProfile, ".java": cFamilyProfile, ".kt": cFamilyProfile, ".kts": cFamilyProfile, ".scala": cFamilyProfile,
+	".swift": cFamilyProfile, ".dart": cFamilyProfile, ".groovy": cFamilyProfile, ".gradle": cFamilyProfile,
+	".js": cFamilyProfile, ".jsx": cFamilyProfile, ".mjs": cFamilyProfile, ".cjs": cFamilyProfile,
+	".ts": cFamilyProfile, ".tsx": cFamilyProfile, ".proto": cFamilyProfile, ".scss": cFamilyProfile, ".less": cFamilyProfile,
+	".rs":  rustProfile,
+	".css": cssProfile,
+	".sh":  hashProfile, ".bash": hashProfile, ".zsh": hashProfile, ".rb": scriptProfile, ".pl": scriptProfile, ".r": scriptProfile,
+	".yml": hashProfile, ".yaml": hashProfile, ".toml": hashProfile, ".mk": hashProfile, ".cmake": hashProfile,
+	".conf": hashProfile, ".dockerfile": hashProfile, ".tf": hashProfile, ".ps1": hashProfile,
+	".py":  pythonProfile,
+	".ini": iniProfile, ".cfg": iniProfile,
+	".sql":  sqlProfile,
+	".lua":  luaProfile,
+	".html": markupProfile, ".htm": markupProfile, ".xhtml": markupProfile, ".xml": markupProfile, ".svg": markupProfile,
+	".md": markupProfile, ".markdown": markupProfile, ".vue": markupProfile,
+}
+
+// profileFor picks the lexer profile for path by its extension, or nil when there's none.
+func profileFor(path string) *lexerProfile {
+	return lexerProfiles[strings.ToLower(filepath.Ext(path))]
+}
+
+// regions splits content into comment spans and string literal spans, the latter without their quotes.
+func (profile *lexerProfile) regions(content string) (comments, literals []span) {
+	for i := 0; i < len(content); {
+		if end, ok := profile.blockComment(content, i); ok {
+			comments = append(comments, span{i, end})
+			i = end
+			continue
+		}
+		if end, ok := profile.lineComment(content, i); ok {
+			comments = append(comments, span{i, end})
+			i = end
+			continue
+		}
+		if quote, ok := profile.quoteAt(content, i); ok {
+			start := i + len(quote.open)
+			end, next := quote.find(content, start)
+			literals = append(literals, span{start, end})
+			i = next
+			continue
+		}
+		i++
+	}
+	return comments, literals
+}
+
+// blockComment reports whether a block comment opens at i, and where it ends.
+func (profile *lexerProfile) blockComment(content string, i int) (int, bool) {
+	for _, delimiters := range profile.blockComments {
+		if !strings.HasPrefix(content[i:], delimiters[0]) {
+			continue
+		}
+		end := strings.Index(content[i+len(delimiters[0]):], delimiters[1])
+		if end < 0 {
+			return len(content), true
+		}
+		return i + len(delimiters[0]) + end + len(delimiters[1]), true
+	}
+	return 0, false
+}
+
+// lineComment reports whether a line comment starts at i, and where it ends, not counting the line break.
+func (profile *lexerProfile) lineComment(content string, i int) (int, bool) {
+	for _, marker := range profile.lineComments {
+		if !strings.HasPrefix(content[i:], marker) {
+			continue
+		}
+		if profile.commentAfterSpace && i > 0 && !strings.ContainsRune(" \t\r\n", rune(content[i-1])) {
+			continue
+		}
+		end := strings.IndexByte(content[i:], '\n')
+		if end < 0 {
+			return len(content), true
+		}
+		return i + len(strings.TrimSuffix(content[i:i+end], "\r")), true
+	}
+	return 0, false
+}
+
+// quoteAt returns the quote rule whose literal opens at i, trying longer delimiters first as listed.
+func (profile *lexerProfile) quoteAt(content string, i int) (quoteRule, bool) {
+	for _, quote := range profile.quotes {
+		if strings.HasPrefix(content[i:], quote.open) {
+			return quote, true
+		}
+	}
+	return quoteRule{}, false
+}
+
+// find looks for the end of a literal whose contents start at start. It returns where the contents
+// end and where scanning resumes after the closing quote.
+func (quote quoteRule) find(content string, start int) (end, next int) {
+	for i := start; i < len(content); i++ {
+		switch {
+		case quote.escapes && content[i] == '\\':
+			i++
+		case strings.HasPrefix(content[i:], quote.close):
+			return i, i + len(quote.close)
+		case content[i] == '\n' && !quote.multiline:
+			return i, i // An unclosed literal ends with its line.
+		}
+	}
+	return len(content), len(content)
+}
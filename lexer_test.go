@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lex classifies content with the profile picked for path and returns the texts of its comments and literals.
+func lex(t *testing.T, path, content string) (comments, literals []string) {
+	t.Helper()
+	profile := profileFor(path)
+	if profile == nil {
+		t.Fatalf("no lexer profile for %s", path)
+	}
+	commentSpans, literalSpans := profile.regions(content)
+	return regionTexts(content, commentSpans), regionTexts(content, literalSpans)
+}
+
+func TestCFamilyProfile(t *testing.T) {
+	comments, literals := lex(t, "app.ts", "const a = \"say \\\"hi\\\"\"; // one\n/* two\n */ const b = `multi\nline` + 'c' // three")
+
+	if want := []string{"// one", "/* two\n */", "// three"}; !reflect.DeepEqual(comments, want) {
+		t.Errorf("comments = %q, want %q", comments, want)
+	}
+	if want := []string{`say \"hi\"`, "multi\nline", "c"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("literals = %q, want %q", literals, want)
+	}
+}
+
+func TestUnclosedStringEndsWithItsLine(t *testing.T) {
+	comments, literals := lex(t, "Main.java", "s = \"open\nint x = 1; // tail")
+
+	if !reflect.DeepEqual(literals, []string{"open"}) || !reflect.DeepEqual(comments, []string{"// tail"}) {
+		t.Errorf("comments = %q, literals = %q", comments, literals)
+	}
+}
+
+func TestRustLifetimesAreNotStrings(t *testing.T) {
+	_, literals := lex(t, "lib.rs", "fn f<'a>(s: &'a str) -> &'a str { \"x\" }")
+
+	if !reflect.DeepEqual(literals, []string{"x"}) {
+		t.Errorf("literals = %q", literals)
+	}
+}
+
+func TestHashCommentsNeedWhitespaceBefore(t *testing.T) {
+	comments, _ := lex(t, "run.sh", "echo ${#items} # count\n# whole line\nurl=http://x/#anchor")
+
+	if want := []string{"# count", "# whole line"}; !reflect.DeepEqual(comments, want) {
+		t.Errorf("comments = %q, want %q", comments, want)
+	}
+}
+
+func TestShellSingleQuotesHaveNoEscapes(t *testing.T) {
+	// In a shell '\' is a backslash on its own, while Ruby's '\'' is a quote.
+	_, literals := lex(t, "run.sh", "tr '\\' '/' # slashes\nname='old'\n")
+	if want := []string{`\`, "/", "old"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("sh literals = %q, want %q", literals, want)
+	}
+
+	_, literals = lex(t, "app.rb", "s = 'it\\'s' + 'old'\n")
+	if want := []string{`it\'s`, "old"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("rb literals = %q, want %q", literals, want)
+	}
+}
+
+func TestPythonTripleQuotes(t *testing.T) {
+	comments, literals := lex(t, "mod.py", "doc = \"\"\"It's \"quoted\"\nacross lines\"\"\" # note\nname = 'x'")
+
+	if want := []string{"It's \"quoted\"\nacross lines", "x"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("literals = %q, want %q", literals, want)
+	}
+	if !reflect.DeepEqual(comments, []string{"# note"}) {
+		t.Errorf("comments = %q", comments)
+	}
+}
+
+func TestSQLAndLuaProfiles(t *testing.T) {
+	comments, literals := lex(t, "q.sql", "SELECT 'it''s' -- trailing\n/* block */")
+	if want := []string{"-- trailing", "/* block */"}; !reflect.DeepEqual(comments, want) {
+		t.Errorf("sql comments = %q, want %q", comments, want)
+	}
+	if want := []string{"it", "s"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("sql literals = %q, want %q: a doubled quote closes and reopens", literals, want)
+	}
+
+	comments, literals = lex(t, "init.lua", "--[[ long\ncomment ]] s = [[raw\nstring]] -- short")
+	if want := []string{"--[[ long\ncomment ]]", "-- short"}; !reflect.DeepEqual(comments, want) {
+		t.Errorf("lua comments = %q, want %q", comments, want)
+	}
+	if want := []string{"raw\nstring"}; !reflect.DeepEqual(literals, want) {
+		t.Errorf("lua literals = %q, want %q", literals, want)
+	}
+}
+
+func TestMarkupHasOnlyComments(t *testing.T) {
+	comments, literals := lex(t, "page.HTML", "<p class=\"x\">Don't</p><!-- note -->")
+
+	if !reflect.DeepEqual(comments, []string{"<!-- note -->"}) || len(literals) != 0 {
+		t.Errorf("comments = %q, literals = %q", comments, literals)
+	}
+}
+
+func TestProfileScopedReplacement(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Scope: scopeStrings}, NewAppContext())
+
+	got := shiftFile(t, ns, "settings.py", "user = 'user' # user\n", ns.newRule("user", "member"))
+	if want := "user = 'member' # user\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if ns.canScope("notes.txt") {
+		t.Error("a .txt file has no profile and shouldn't be scoped")
+	}
+}
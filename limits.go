@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// lineKey identifies a rule's matches on one line of a file.
+type lineKey struct {
+	rule *Rule
+	line int
+}
+
+// validateLimits checks the occurrence limit flags before anything is replaced.
+func validateLimits(cfg *Config) error {
+	if cfg.Nth < 0 || cfg.First < 0 || cfg.MaxReplacements < 0 {
+		return fmt.Errorf("occurrence limits can't be negative")
+	}
+	if cfg.NthScope != "file" && cfg.NthScope != "line" {
+		return fmt.Errorf("invalid -nth-scope %q, expected \"file\" or \"line\"", cfg.NthScope)
+	}
+	return nil
+}
+
+// allowOccurrence decides whether a rule's match may be replaced under the occurrence limits: only the
+// Nth match per file or per line, only the first N matches per file, and no more than a maximum number
+// of replacements over the whole run. Every match of a rule is counted, replaced or not.
+func (ns *NameShifter) allowOccurrence(original string, rule *Rule, match []int, state *fileState) bool {
+	cfg := ns.Config
+	if cfg.Nth == 0 && cfg.First == 0 && cfg.MaxReplacements == 0 {
+		return true
+	}
+
+	state.seen[rule]++
+	if cfg.First > 0 && state.seen[rule] > cfg.First {
+		return false
+	}
+	if cfg.Nth > 0 {
+		occurrence := state.seen[rule]
+		if cfg.NthScope == "line" {
+			key := lineKey{rule, state.lineOf(original, match[0])}
+			state.seenOnLine[key]++
+			occurrence = state.seenOnLine[key]
+		}
+		if occurrence != cfg.Nth {
+			return false
+		}
+	}
+	return ns.reserveReplacement()
+}
+
+// reserveReplacement claims one replacement from the run-wide maximum, if there is one.
+func (ns *NameShifter) reserveReplacement() bool {
+	if ns.Config.MaxReplacements == 0 {
+		return true
+	}
+	for {
+		reserved := atomic.LoadInt64(&ns.reserved)
+		if reserved >= int64(ns.Config.MaxReplacements) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&ns.reserved, reserved, reserved+1) {
+			return true
+		}
+	}
+}
+
+// lineOf returns the line number of offset within text, whose first line is state.line, or 0 for names.
+func (state *fileState) lineOf(text string, offset int) int {
+	if state.line == 0 {
+		return 0
+	}
+	return state.line + strings.Count(text[:offset], "\n")
+}
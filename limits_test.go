@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const occurrences = "x x x\nx x\nx\n"
+
+func TestNthPerFile(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Nth: 4, NthScope: "file"}, NewAppContext())
+
+	if got := shiftFile(t, ns, "a.md", occurrences, ns.newRule("x", "y")); got != "x x x\ny x\nx\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNthPerLine(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Nth: 2, NthScope: "line"}, NewAppContext())
+
+	if got := shiftFile(t, ns, "a.md", occurrences, ns.newRule("x", "y")); got != "x y x\nx y\nx\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNthPerLineInMultilineMode(t *testing.T) {
+	// The whole file is one piece of text, the line of each match still decides its occurrence.
+	ns := NewNameShifter(&Config{CaseMatching: true, Multiline: true, Nth: 1, NthScope: "line"}, NewAppContext())
+
+	if got := shiftFile(t, ns, "a.md", occurrences, ns.newRule("x", "y")); got != "y x x\ny x\ny\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFirstCountsEachRuleSeparately(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, First: 2}, NewAppContext())
+	rules := []*Rule{ns.newRule("x", "y"), ns.newRule("y", "z")}
+
+	// The second rule rewrites the output of the first, with two replacements of its own.
+	got := shiftFile(t, ns, "a.md", occurrences, rules...)
+	if want := "z z x\nx x\nx\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMaxReplacementsSpansTheRun(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 4; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("%d.md", i)), []byte("x x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ns := NewNameShifter(&Config{CaseMatching: true, ConcurrentRun: true, MaxReplacements: 5, FileExtensions: []string{".md"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.ProcessAllPaths(paths, []*Rule{ns.newRule("x", "y")})
+
+	if ns.Context.replacementsCount != 5 {
+		t.Errorf("replacements = %d, want exactly 5 across the concurrent run", ns.Context.replacementsCount)
+	}
+}
+
+func TestLimitsApplyToSimultaneousRules(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Simultaneous: true, Nth: 2, NthScope: "file"}, NewAppContext())
+	rules := []*Rule{ns.newRule("a", "b"), ns.newRule("b", "a")}
+
+	if got := ns.applyRules("a b a b", rules, newFileState("x")); got != "a b b a" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	valid := Config{NthScope: "line", Nth: 2, First: 1, MaxReplacements: 3}
+	if err := validateLimits(&valid); err != nil {
+		t.Errorf("validateLimits(%+v) = %v", valid, err)
+	}
+	for _, cfg := range []Config{
+		{NthScope: "file", Nth: -1},
+		{NthScope: "file", MaxReplacements: -2},
+		{NthScope: "word"},
+	} {
+		if err := validateLimits(&cfg); err == nil {
+			t.Errorf("validateLimits(%+v) should fail", cfg)
+		}
+	}
+}
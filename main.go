@@ -5,28 +5,79 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
+	"golang.org/x/text/transform"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // Config encapsulates application-wide configurations.
 type Config struct {
-	IgnoreConfig   bool
-	WorkGlobally   bool
-	ConcurrentRun  bool
-	CaseMatching   bool
+	IgnoreConfig  bool
+	WorkGlobally  bool
+	ConcurrentRun bool
+	CaseMatching  bool
+	Regex         bool
+	PreserveCase  bool
+	Variants      bool
+	WholeWord     bool
+	IdentWord     bool
+	RulesFile     string
+	Simultaneous  bool
+	Swap          bool
+	Multiline     bool
+	Escapes       bool
+	Hex           bool
+	Template      bool
+
+	LinesMatching    string
+	LinesNotMatching string
+	LineRanges       string
+	FromMarker       string
+	ToMarker         string
+
+	Nth             int
+	NthScope        string
+	First           int
+	MaxReplacements int
+
+	Scope    string
+	Data     string
+	DataPath string
+	Markdown string
+	Anchors  bool
+	Markup   string
+	Columns  string
+	Header   bool
+	Fuzzy    int
+	Accept   string
+
+	Normalize string
+	Fold      bool
+
+	Binary     bool
+	SameLength bool
+	Pad        string
+
+	Encoding  string
+	ConvertTo string
+	EOL       string
+
 	FileExtensions []string
 	VersionFlag    bool
 	Version        string
+	Args           []string // Positional arguments left over after flag parsing.
 }
 
 func NewConfig() *Config {
@@ -41,12 +92,91 @@ func NewConfig() *Config {
 	flag.BoolVar(&cfg.ConcurrentRun, "cr", false, "Run each folder inside the root directory in a separate goroutine 🏃💨")
 	flag.BoolVar(&cfg.CaseMatching, "case-matching", true, "Match case when replacing strings 👔🔍")
 	flag.BoolVar(&cfg.CaseMatching, "cm", true, "Match case when replacing strings 👔🔍")
+	flag.BoolVar(&cfg.Regex, "regex", false, "Treat the search string as a regular expression, the replacement may use $1 or ${name} 🧩🔎")
+	flag.BoolVar(&cfg.Regex, "rx", false, "Treat the search string as a regular expression, the replacement may use $1 or ${name} 🧩🔎")
+	flag.BoolVar(&cfg.PreserveCase, "preserve-case", false, "With case matching off, recase the replacement to follow each match (foo/Foo/FOO) 🔠🪞")
+	flag.BoolVar(&cfg.PreserveCase, "pc", false, "With case matching off, recase the replacement to follow each match (foo/Foo/FOO) 🔠🪞")
+	flag.BoolVar(&cfg.Variants, "variants", false, "Treat both strings as words and replace every identifier spelling (camelCase, PascalCase, snake_case, SCREAMING_CASE, kebab-case) 🐫🐍")
+	flag.BoolVar(&cfg.Variants, "vr", false, "Treat both strings as words and replace every identifier spelling (camelCase, PascalCase, snake_case, SCREAMING_CASE, kebab-case) 🐫🐍")
+	flag.BoolVar(&cfg.WholeWord, "word", false, "Only replace whole words, so 'id' leaves 'width' and 'valid' alone 🧱🔤")
+	flag.BoolVar(&cfg.WholeWord, "w", false, "Only replace whole words, so 'id' leaves 'width' and 'valid' alone 🧱🔤")
+	flag.BoolVar(&cfg.IdentWord, "identifier-word", false, "Like -word, but '_' and camelCase humps also count as word edges (user_id, userId) 🐫🧱")
+	flag.BoolVar(&cfg.IdentWord, "iw", false, "Like -word, but '_' and camelCase humps also count as word edges (user_id, userId) 🐫🧱")
+	flag.StringVar(&cfg.RulesFile, "rules", "", "YAML, JSON, TOML or tab-separated file of replacement rules applied in one pass 📜🔁")
+	flag.StringVar(&cfg.RulesFile, "r", "", "YAML, JSON, TOML or tab-separated file of replacement rules applied in one pass 📜🔁")
+	flag.BoolVar(&cfg.Simultaneous, "simultaneous", false, "Match all rules against the original text and replace them in one left-to-right pass 🔀🧮")
+	flag.BoolVar(&cfg.Simultaneous, "sim", false, "Match all rules against the original text and replace them in one left-to-right pass 🔀🧮")
+	flag.BoolVar(&cfg.Swap, "swap", false, "Exchange the two strings with each other, implies -simultaneous 🔄🤝")
+	flag.BoolVar(&cfg.Swap, "sw", false, "Exchange the two strings with each other, implies -simultaneous 🔄🤝")
+	flag.BoolVar(&cfg.Multiline, "multiline", false, "Match against whole files instead of line by line, so patterns may span line breaks 📄🧵")
+	flag.BoolVar(&cfg.Multiline, "ml", false, "Match against whole files instead of line by line, so patterns may span line breaks 📄🧵")
+	flag.BoolVar(&cfg.Escapes, "escapes", false, "Interpret \\n, \\t, \\0, \\xHH and \\u{...} escapes in the search and replacement strings ⌨️🔣")
+	flag.BoolVar(&cfg.Escapes, "e", false, "Interpret \\n, \\t, \\0, \\xHH and \\u{...} escapes in the search and replacement strings ⌨️🔣")
+	flag.BoolVar(&cfg.Hex, "hex", false, "Read the search and replacement strings as hex bytes, e.g. '09' or '0x0d 0x0a' 🔢🧬")
+	flag.BoolVar(&cfg.Hex, "x", false, "Read the search and replacement strings as hex bytes, e.g. '09' or '0x0d 0x0a' 🔢🧬")
+	flag.BoolVar(&cfg.Template, "template", false, "Treat the replacement as a Go text/template with the match, groups, file and line at hand 🧾🛠️")
+	flag.BoolVar(&cfg.Template, "tpl", false, "Treat the replacement as a Go text/template with the match, groups, file and line at hand 🧾🛠️")
+	flag.StringVar(&cfg.LinesMatching, "lines-matching", "", "Only replace on lines matching this regex, e.g. 'route\\(' 🎯📏")
+	flag.StringVar(&cfg.LinesMatching, "lm", "", "Only replace on lines matching this regex, e.g. 'route\\(' 🎯📏")
+	flag.StringVar(&cfg.LinesNotMatching, "lines-not-matching", "", "Leave lines matching this regex untouched 🙈📏")
+	flag.StringVar(&cfg.LinesNotMatching, "lnm", "", "Leave lines matching this regex untouched 🙈📏")
+	flag.StringVar(&cfg.LineRanges, "line-range", "", "Only replace within these line numbers, e.g. '3,10-20,40-' 🔢📏")
+	flag.StringVar(&cfg.LineRanges, "lr", "", "Only replace within these line numbers, e.g. '3,10-20,40-' 🔢📏")
+	flag.StringVar(&cfg.FromMarker, "from-marker", "", "Only replace in blocks starting at a line matching this regex 🚩📏")
+	flag.StringVar(&cfg.FromMarker, "fm", "", "Only replace in blocks starting at a line matching this regex 🚩📏")
+	flag.StringVar(&cfg.ToMarker, "to-marker", "", "Only replace in blocks ending at a line matching this regex 🏁📏")
+	flag.StringVar(&cfg.ToMarker, "tm", "", "Only replace in blocks ending at a line matching this regex 🏁📏")
+	flag.IntVar(&cfg.Nth, "nth", 0, "Only replace the Nth match of each rule, per file or per line (see -nth-scope) 🎯🔢")
+	flag.IntVar(&cfg.Nth, "n", 0, "Only replace the Nth match of each rule, per file or per line (see -nth-scope) 🎯🔢")
+	flag.StringVar(&cfg.NthScope, "nth-scope", "file", "Count -nth matches per 'file' or per 'line' 🧮📄")
+	flag.StringVar(&cfg.NthScope, "ns", "file", "Count -nth matches per 'file' or per 'line' 🧮📄")
+	flag.IntVar(&cfg.First, "first", 0, "Only replace the first N matches of each rule in every file ✂️🥇")
+	flag.IntVar(&cfg.First, "f", 0, "Only replace the first N matches of each rule in every file ✂️🥇")
+	flag.IntVar(&cfg.MaxReplacements, "max", 0, "Stop replacing once this many replacements were made in the whole run 🛑🔢")
+	flag.IntVar(&cfg.MaxReplacements, "m", 0, "Stop replacing once this many replacements were made in the whole run 🛑🔢")
+	flag.StringVar(&cfg.Scope, "scope", "", "Only replace in 'comments', 'strings' or 'code', leaving files in languages nsh can't classify alone 🔬📝")
+	flag.StringVar(&cfg.Scope, "sc", "", "Only replace in 'comments', 'strings' or 'code', leaving files in languages nsh can't classify alone 🔬📝")
+	flag.StringVar(&cfg.Data, "data", "", "In JSON, YAML and TOML files only replace in 'keys' or 'values', keeping formatting and comments 🗂️🔑")
+	flag.StringVar(&cfg.Data, "dt", "", "In JSON, YAML and TOML files only replace in 'keys' or 'values', keeping formatting and comments 🗂️🔑")
+	flag.StringVar(&cfg.DataPath, "data-path", "", "Only replace at this path of JSON, YAML and TOML files, e.g. 'services.*.image', implies -data=values 🧭🗂️")
+	flag.StringVar(&cfg.DataPath, "dp", "", "Only replace at this path of JSON, YAML and TOML files, e.g. 'services.*.image', implies -data=values 🧭🗂️")
+	flag.StringVar(&cfg.Markdown, "markdown", "", "In Markdown files only replace in 'prose', 'code' (fenced and inline) or 'links' (targets) 📝🔗")
+	flag.StringVar(&cfg.Markdown, "md", "", "In Markdown files only replace in 'prose', 'code' (fenced and inline) or 'links' (targets) 📝🔗")
+	flag.BoolVar(&cfg.Anchors, "anchors", false, "Update #anchor links when a replacement renames a Markdown heading ⚓🔗")
+	flag.BoolVar(&cfg.Anchors, "an", false, "Update #anchor links when a replacement renames a Markdown heading ⚓🔗")
+	flag.StringVar(&cfg.Markup, "markup", "", "In XML and HTML files only replace in 'elements', 'attributes', 'values' or 'text', comma separated 🏷️🧩")
+	flag.StringVar(&cfg.Markup, "mu", "", "In XML and HTML files only replace in 'elements', 'attributes', 'values' or 'text', comma separated 🏷️🧩")
+	flag.StringVar(&cfg.Columns, "columns", "", "In CSV and TSV files only replace in these columns, by header name or number, comma separated 📊🎯")
+	flag.StringVar(&cfg.Columns, "co", "", "In CSV and TSV files only replace in these columns, by header name or number, comma separated 📊🎯")
+	flag.BoolVar(&cfg.Header, "header", true, "Treat the first CSV or TSV row as a header naming the columns, and leave it alone 🏷️📊")
+	flag.BoolVar(&cfg.Header, "hd", true, "Treat the first CSV or TSV row as a header naming the columns, and leave it alone 🏷️📊")
+	flag.IntVar(&cfg.Fuzzy, "fuzzy", 0, "List near misses within N edits of each search string instead of replacing, see -accept 🔎🤏")
+	flag.IntVar(&cfg.Fuzzy, "fz", 0, "List near misses within N edits of each search string instead of replacing, see -accept 🔎🤏")
+	flag.StringVar(&cfg.Accept, "accept", "", "Replace these near misses found with -fuzzy too, comma separated, or 'all' ✅🤏")
+	flag.StringVar(&cfg.Accept, "ac", "", "Replace these near misses found with -fuzzy too, comma separated, or 'all' ✅🤏")
+	flag.StringVar(&cfg.Normalize, "normalize", "", "Match text and search strings in Unicode normal form 'nfc', 'nfd', 'nfkc' or 'nfkd' 🔣⚖️")
+	flag.StringVar(&cfg.Normalize, "nf", "", "Match text and search strings in Unicode normal form 'nfc', 'nfd', 'nfkc' or 'nfkd' 🔣⚖️")
+	flag.BoolVar(&cfg.Fold, "fold", false, "Match with full Unicode case folding, so 'STRASSE' matches 'straße' 🔡🔠")
+	flag.BoolVar(&cfg.Fold, "fd", false, "Match with full Unicode case folding, so 'STRASSE' matches 'straße' 🔡🔠")
+	flag.BoolVar(&cfg.Binary, "binary", false, "Replace in the raw bytes of files and report the offset of each patch 💾🩹")
+	flag.BoolVar(&cfg.Binary, "bn", false, "Replace in the raw bytes of files and report the offset of each patch 💾🩹")
+	flag.BoolVar(&cfg.SameLength, "same-length", false, "With -binary, pad or truncate replacements to the length of the match so offsets stay valid 📏💾")
+	flag.BoolVar(&cfg.SameLength, "sl", false, "With -binary, pad or truncate replacements to the length of the match so offsets stay valid 📏💾")
+	flag.StringVar(&cfg.Pad, "pad", "", "The byte -same-length pads replacements with, \\0 by default 🧱💾")
+	flag.StringVar(&cfg.Pad, "pd", "", "The byte -same-length pads replacements with, \\0 by default 🧱💾")
+	flag.StringVar(&cfg.Encoding, "encoding", "", "Read and write files in this encoding, e.g. 'utf-16le' or 'latin1', instead of detecting it 🈂️🔤")
+	flag.StringVar(&cfg.Encoding, "enc", "", "Read and write files in this encoding, e.g. 'utf-16le' or 'latin1', instead of detecting it 🈂️🔤")
+	flag.StringVar(&cfg.ConvertTo, "convert-to", "", "Write every processed file in this encoding, e.g. 'utf-8', converting it 🔄🔤")
+	flag.StringVar(&cfg.ConvertTo, "ct", "", "Write every processed file in this encoding, e.g. 'utf-8', converting it 🔄🔤")
+	flag.StringVar(&cfg.EOL, "eol", eolPreserve, "Line endings to write: 'lf', 'crlf', or 'preserve' those of each line ↩️📝")
+	flag.StringVar(&cfg.EOL, "el", eolPreserve, "Line endings to write: 'lf', 'crlf', or 'preserve' those of each line ↩️📝")
 	var fileExtensions string
 	flag.StringVar(&fileExtensions, "file-extensions", ".go,.md", "Comma-separated list of file extensions to process, e.g., '.go,.md' 📄✂️")
 	flag.StringVar(&fileExtensions, "ext", ".go,.md", "Comma-separated list of file extensions to process, e.g., '.go,.md' 📄✂️")
 	flag.StringVar(&fileExtensions, "exts", ".go,.md", "Comma-separated list of file extensions to process, e.g., '.go,.md' 📄✂️")
 
-	flag.Parse()
+	cfg.Args = parseInterspersedFlags(os.Args[1:])
+	cfg.Simultaneous = cfg.Simultaneous || cfg.Swap
 
 	cfg.FileExtensions = strings.Split(fileExtensions, ",")
 	for i, ext := range cfg.FileExtensions {
@@ -55,9 +185,32 @@ func NewConfig() *Config {
 	return cfg
 }
 
+// parseInterspersedFlags parses flags wherever they appear and returns the positional arguments,
+// so flags may follow the directory and strings as shown in the usage examples. A "--" ends the flags,
+// everything after it is positional, so strings starting with "-" can be passed.
+func parseInterspersedFlags(arguments []string) []string {
+	var positional []string
+	for {
+		// The command line flag set exits on parse errors, so there is no error to handle here.
+		_ = flag.CommandLine.Parse(arguments)
+		if parsed := len(arguments) - flag.NArg(); parsed > 0 && arguments[parsed-1] == "--" {
+			return append(positional, flag.Args()...)
+		}
+		arguments = flag.Args()
+		if len(arguments) == 0 {
+			return positional
+		}
+		positional = append(positional, arguments[0])
+		arguments = arguments[1:]
+	}
+}
+
 func customFlagParsing() {
 	//log.Println("> Inside customFlagParsing")
 	for i, arg := range os.Args {
+		if arg == "--" {
+			break // The end of the flags, the arguments after it are left as they are.
+		}
 		if strings.HasPrefix(arg, "--") {
 			//log.Println("> Inside customFlagParsing for loop")
 			os.Args[i] = strings.Replace(arg, "--", "-", -1)
@@ -73,8 +226,20 @@ func init() {
 
 // NameShifter encapsulates all functionalities related to the name shifting process.
 type NameShifter struct {
-	Config  *Config
-	Context *AppContext
+	Config    *Config
+	Context   *AppContext
+	patterns  sync.Map       // Compiled search patterns keyed by their expression.
+	prefilter *regexp.Regexp // Matches anything any rule could match, see prepareRules.
+	root      string         // Starting directory, rule path filters are relative to it.
+	counters  map[string]int // Values handed out by the template counter function.
+	counterMu sync.Mutex     // Protects counters.
+	address   *lineAddress   // Lines replacements are limited to, nil for all of them.
+	reserved  int64          // Replacements claimed from -max so far.
+
+	anchorRenames map[string]map[string]string // Renamed heading anchors by absolute file path, see -anchors.
+	anchorMu      sync.Mutex                   // Protects anchorRenames.
+	renames       map[string]string            // Absolute paths of renamed entities by their old ones, see currentPath.
+	renameMu      sync.Mutex                   // Protects renames.
 }
 
 // NewNameShifter creates a new instance of NameShifter with given configuration and context.
@@ -87,6 +252,7 @@ func NewNameShifter(cfg *Config, ctx *AppContext) *NameShifter {
 
 // collectPaths walks the starting directory and collects all paths.
 func (ns *NameShifter) collectPaths(startingDir string) ([]string, error) {
+	ns.root = startingDir
 	var paths []string
 	err := filepath.Walk(startingDir, func(path string, info os.FileInfo, err error) error {
 		//fmt.Printf("Visiting: %s\n", path)
@@ -114,39 +280,71 @@ func (ns *NameShifter) collectPaths(startingDir string) ([]string, error) {
 }
 
 // ProcessAllPaths decides whether to process paths concurrently or sequentially based on the configuration.
-func (ns *NameShifter) ProcessAllPaths(paths []string, theStringToBeReplaced, theReplacementString string) {
-	if ns.Config.ConcurrentRun {
-		ns.processPathsConcurrently(paths, theStringToBeReplaced, theReplacementString)
-	} else {
-		ns.processPathsSequentially(paths, theStringToBeReplaced, theReplacementString)
+func (ns *NameShifter) ProcessAllPaths(paths []string, rules []*Rule) {
+	ns.prepareRules(rules)
+	batches := [][]string{paths}
+	if ns.Config.WorkGlobally {
+		batches = deepestFirst(paths)
+	}
+	for _, batch := range batches {
+		if ns.Config.ConcurrentRun {
+			ns.processPathsConcurrently(batch, rules)
+		} else {
+			ns.processPathsSequentially(batch, rules)
+		}
+	}
+	if ns.Config.Anchors {
+		ns.updateAnchorLinks(paths)
 	}
 }
 
+// deepestFirst groups paths by depth, deepest first. Renaming a directory changes the path of everything
+// below it, so everything below it is done by the time a directory is renamed; paths at the same depth can't
+// be below one another and are processed together.
+func deepestFirst(paths []string) [][]string {
+	byDepth := make(map[int][]string)
+	var depths []int
+	for _, path := range paths {
+		depth := strings.Count(filepath.Clean(path), string(filepath.Separator))
+		if _, ok := byDepth[depth]; !ok {
+			depths = append(depths, depth)
+		}
+		byDepth[depth] = append(byDepth[depth], path)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(depths)))
+
+	batches := make([][]string, 0, len(depths))
+	for _, depth := range depths {
+		batches = append(batches, byDepth[depth])
+	}
+	return batches
+}
+
 // processPathsConcurrently processes paths in parallel using goroutines.
-func (ns *NameShifter) processPathsConcurrently(paths []string, theStringToBeReplaced, theReplacementString string) {
+func (ns *NameShifter) processPathsConcurrently(paths []string, rules []*Rule) {
 	var wg sync.WaitGroup
 	for _, path := range paths {
 		wg.Add(1)
 		go func(p string) {
 			defer wg.Done()
-			ns.processSinglePath(p, theStringToBeReplaced, theReplacementString)
+			ns.processSinglePath(p, rules)
 		}(path)
 	}
 	wg.Wait()
 }
 
 // processPathsSequentially processes paths one after another.
-func (ns *NameShifter) processPathsSequentially(paths []string, theStringToBeReplaced, theReplacementString string) {
+func (ns *NameShifter) processPathsSequentially(paths []string, rules []*Rule) {
 	for _, path := range paths {
-		ns.processSinglePath(path, theStringToBeReplaced, theReplacementString)
+		ns.processSinglePath(path, rules)
 	}
 }
 
 // processSinglePath processes a single path, deciding whether to rename the entity and/or process the file.
-func (ns *NameShifter) processSinglePath(path, theStringToBeReplaced, theReplacementString string) {
+func (ns *NameShifter) processSinglePath(path string, rules []*Rule) {
 	info, err := os.Stat(path)
 	if err != nil {
-		ns.Context.AddError()
+		ns.reportError(path, err)
 		return
 	}
 
@@ -155,20 +353,27 @@ func (ns *NameShifter) processSinglePath(path, theStringToBeReplaced, theReplace
 		return // Skip this path due to error or it being a directory we're ignoring
 	}
 
-	// Handling directories and files
-	if ns.Config.WorkGlobally && (info.IsDir() || strings.Contains(info.Name(), theStringToBeReplaced)) {
-		if err := ns.renameEntity(path, theStringToBeReplaced, theReplacementString); err != nil {
-			ns.Context.AddError()
+	// A file's contents are rewritten before it's renamed, so a file whose name matches too gets both.
+	if !info.IsDir() && ns.shouldProcessFile(path, info) {
+		if err := ns.processFile(path, rules); err != nil {
+			ns.reportError(path, err)
 			return
 		}
-	} else if !info.IsDir() && ns.shouldProcessFile(path, info) {
-		if err := ns.processFile(path, theStringToBeReplaced, theReplacementString); err != nil {
-			ns.Context.AddError()
-			return
+	}
+	if ns.Config.WorkGlobally && (info.IsDir() || ns.containsMatch(info.Name(), rules)) {
+		if err := ns.renameEntity(path, rules); err != nil {
+			ns.reportError(path, err)
 		}
 	}
 }
 
+// reportError counts an error processing path and adds it to the error report. Functions processing a
+// single path return their errors rather than report them, so each is counted once, here.
+func (ns *NameShifter) reportError(path string, err error) {
+	ns.Context.AddError()
+	ns.Context.AddErrorReportRow([]table.Row{{"Path", path, "Error", err.Error()}})
+}
+
 func (ns *NameShifter) ignoreConfigDirs(path string, err error) error {
 	dirName := filepath.Base(path)
 	// Check if the path contains `venv` as a segment to ensure it's skipped appropriately
@@ -187,28 +392,275 @@ func (ns *NameShifter) ignoreConfigDirs(path string, err error) error {
 	return nil
 }
 
+// fileState tracks where in a file or name the rules are being applied and how many replacements each rule made there.
+type fileState struct {
+	path  string
+	line  int           // Line number of the first line of the text being rewritten, 0 for names.
+	tally map[*Rule]int // Replacements made per rule.
 
-// replaceString replaces all occurrences of toReplace with replacement in the original string.
-func (ns *NameShifter) replaceString(original, toReplace, replacement string) string {
-	if ns.Config.CaseMatching {
-		return strings.Replace(original, toReplace, replacement, -1)
+	inBlock, blockClosed bool            // Where the marker blocks of the line address stand.
+	seen                 map[*Rule]int   // Matches per rule, replaced or not, for the occurrence limits.
+	seenOnLine           map[lineKey]int // The same per line.
+}
+
+func newFileState(path string) *fileState {
+	return &fileState{
+		path:       path,
+		tally:      make(map[*Rule]int),
+		seen:       make(map[*Rule]int),
+		seenOnLine: make(map[lineKey]int),
 	}
-	regex := regexp.MustCompile("(?i)" + regexp.QuoteMeta(toReplace))
-	return regex.ReplaceAllString(original, replacement)
 }
 
-func (ns *NameShifter) processFile(path, theStringToBeReplaced, theReplacementString string) error {
-	originalFile, err := os.Open(path)
+// applyRules runs every rule over text in order, counting the replacements each rule makes in state.
+func (ns *NameShifter) applyRules(text string, rules []*Rule, state *fileState) string {
+	if ns.prefilter != nil && !ns.prefilter.MatchString(text) {
+		return text // No rule can match, so none of them can change anything.
+	}
+	if ns.Config.Simultaneous {
+		return applyEdits(text, ns.simultaneousEdits(text, rules, state))
+	}
+	for _, rule := range rules {
+		text = ns.replaceString(text, rule, state)
+	}
+	return text
+}
+
+// textEdit replaces text[start:end] with replacement.
+type textEdit struct {
+	start, end  int
+	replacement string
+}
+
+// applyEdits applies edits, which are sorted and don't overlap, to text.
+func applyEdits(text string, edits []textEdit) string {
+	if len(edits) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, edit := range edits {
+		b.WriteString(text[last:edit.start])
+		b.WriteString(edit.replacement)
+		last = edit.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// simultaneousEdits matches every rule against the original text and replaces the matches in a
+// single left-to-right pass, so replacements never feed later rules and a→b, b→a swaps the two.
+// Where matches overlap, the leftmost one wins, and the earlier rule wins a tie.
+func (ns *NameShifter) simultaneousEdits(text string, rules []*Rule, state *fileState) []textEdit {
+	type candidate struct {
+		rule  int
+		match []int
+	}
+	var candidates []candidate
+	for i, rule := range rules {
+		for _, match := range ns.findMatches(text, rule) {
+			candidates = append(candidates, candidate{i, match})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].match[0] != candidates[b].match[0] {
+			return candidates[a].match[0] < candidates[b].match[0]
+		}
+		return candidates[a].rule < candidates[b].rule
+	})
+
+	var edits []textEdit
+	last := 0
+	for _, c := range candidates {
+		if c.match[0] < last {
+			continue // Overlaps a match that's already been replaced.
+		}
+		if !ns.allowOccurrence(text, rules[c.rule], c.match, state) {
+			continue
+		}
+		edits = append(edits, textEdit{c.match[0], c.match[1], ns.expandReplacement(text, rules[c.rule], c.match, state)})
+		last = c.match[1]
+	}
+	return edits
+}
+
+// recordTally adds the per-rule replacement counts gathered for a path to the run totals.
+func (ns *NameShifter) recordTally(state *fileState) {
+	for rule, count := range state.tally {
+		ns.Context.AddRuleReplacements(rule, count)
+	}
+}
+
+// replaceString replaces all matches of the rule in the original string, counting them in state.
+// In regex mode the search string is an RE2 pattern and the replacement may reference capture groups.
+func (ns *NameShifter) replaceString(original string, rule *Rule, state *fileState) string {
+	return applyEdits(original, ns.ruleEdits(original, rule, state))
+}
+
+// ruleEdits returns the replacements the rule makes in original, counting them in state.
+func (ns *NameShifter) ruleEdits(original string, rule *Rule, state *fileState) []textEdit {
+	var edits []textEdit
+	for _, match := range ns.findMatches(original, rule) {
+		if !ns.allowOccurrence(original, rule, match, state) {
+			continue
+		}
+		edits = append(edits, textEdit{match[0], match[1], ns.expandReplacement(original, rule, match, state)})
+	}
+	return edits
+}
+
+// findMatches returns the submatch indices of every non-overlapping match of the rule in text
+// that sits on the word boundaries the rule asks for.
+func (ns *NameShifter) findMatches(text string, rule *Rule) [][]int {
+	if ns.Config.Normalize != "" || ns.Config.Fold {
+		return ns.findCanonicalMatches(text, rule)
+	}
+	return ns.findRawMatches(text, rule)
+}
+
+// findRawMatches is findMatches comparing text and search string byte for byte.
+func (ns *NameShifter) findRawMatches(text string, rule *Rule) [][]int {
+	if rule.CaseMatching && !rule.Regex {
+		var matches [][]int
+		for offset := 0; ; {
+			index := strings.Index(text[offset:], rule.Search)
+			if index < 0 {
+				return matches
+			}
+			start := offset + index
+			if !atBoundaries(text, start, start+len(rule.Search), rule.Boundary) {
+				// Retry from the next character, an overlapping occurrence may still be a whole word.
+				_, size := utf8.DecodeRuneInString(text[start:])
+				offset = start + size
+				continue
+			}
+			offset = start + len(rule.Search)
+			matches = append(matches, []int{start, offset})
+		}
+	}
+	regex, err := ns.compilePattern(rule)
+	if err != nil {
+		// Rules are validated before processing starts, so this only guards against misuse.
+		return nil
+	}
+	matches := regex.FindAllStringSubmatchIndex(text, -1)
+	if rule.Boundary == boundaryNone {
+		return matches
+	}
+	kept := matches[:0]
+	for _, match := range matches {
+		if atBoundaries(text, match[0], match[1], rule.Boundary) {
+			kept = append(kept, match)
+		}
+	}
+	return kept
+}
+
+// expandReplacement counts a single match in state and builds the text that replaces it, rendering the
+// rule's template or expanding capture groups in regex mode, then recasing it to follow the match when case is preserved.
+// A match whose template fails to render is left as it was, uncounted, and the failure is reported.
+func (ns *NameShifter) expandReplacement(original string, rule *Rule, match []int, state *fileState) string {
+	replacement := rule.Replacement
+	switch {
+	case rule.template != nil:
+		rendered, err := ns.executeTemplate(original, rule, match, state)
+		if err != nil {
+			ns.Context.AddError()
+			ns.Context.AddErrorReportRow([]table.Row{{"Path", state.path, "Error", fmt.Sprintf("Template failed: %v", err)}})
+			return original[match[0]:match[1]]
+		}
+		replacement = rendered
+	case rule.Regex:
+		regex, _ := ns.compilePattern(rule)
+		replacement = string(regex.ExpandString(nil, rule.Replacement, original, match))
+	}
+	state.tally[rule]++
+	if (!rule.CaseMatching || ns.Config.Fold) && rule.PreserveCase {
+		replacement = matchCase(original[match[0]:match[1]], rule.Search, replacement)
+	}
+	return replacement
+}
+
+// containsMatch reports whether any of the rules matches s.
+func (ns *NameShifter) containsMatch(s string, rules []*Rule) bool {
+	for _, rule := range rules {
+		if len(ns.findMatches(s, rule)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// patternExpression returns the regular expression source matching a rule's search string.
+func patternExpression(rule *Rule) string {
+	expression := rule.Search
+	if !rule.Regex {
+		expression = regexp.QuoteMeta(rule.Search)
+	}
+	if !rule.CaseMatching {
+		expression = "(?i)" + expression
+	}
+	return expression
+}
+
+// compilePattern builds the regular expression used for a rule and caches it,
+// so it's compiled once per run instead of once per line.
+func (ns *NameShifter) compilePattern(rule *Rule) (*regexp.Regexp, error) {
+	expression := patternExpression(rule)
+	if cached, ok := ns.patterns.Load(expression); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	regex, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", rule.Search, err)
+	}
+	ns.patterns.Store(expression, regex)
+	return regex, nil
+}
+
+func (ns *NameShifter) processFile(path string, rules []*Rule) error {
+	rules = ns.rulesFor(path, rules)
+	if len(rules) == 0 {
+		return nil // Every rule's path filters exclude this file.
+	}
+	if ns.Config.Scope != scopeAll && !ns.canScope(path) {
+		return nil // There's no telling which parts of this file belong to the scope.
+	}
+	if ns.Config.Data != dataNone && !isDataFile(path) {
+		return nil // Only structured data files have keys and values.
+	}
+	if ns.Config.Markdown != markdownAll && !isMarkdownFile(path) {
+		return nil // Prose, code and links are told apart in Markdown files only.
+	}
+	if ns.Config.Markup != markupAll && !isMarkupFile(path) {
+		return nil // Only XML and HTML files have elements and attributes.
+	}
+	if ns.Config.Columns != "" && !isTableFile(path) {
+		return nil // Only CSV and TSV files have columns.
+	}
+	if ns.Config.Binary {
+		return ns.processBinaryFile(path, rules)
+	}
+	if format := officeFormatOf(path); format != nil {
+		return ns.processOfficeFile(path, format, rules)
+	}
+
+	content, err := os.ReadFile(path)
 	if err != nil {
-		ns.Context.AddError()
 		return err
 	}
-	defer originalFile.Close()
+	var reader io.Reader = bytes.NewReader(content)
+	decoder, encoder := ns.fileTransforms(content)
+	if decoder != nil {
+		reader = transform.NewReader(reader, decoder)
+	}
 
 	// Create a temp file
 	tempFile, err := os.CreateTemp("", "nsh_temp_file_")
 	if err != nil {
-		ns.Context.AddError()
 		return err
 	}
 	defer func() {
@@ -216,49 +668,153 @@ func (ns *NameShifter) processFile(path, theStringToBeReplaced, theReplacementSt
 		os.Remove(tempFile.Name()) // Cleanup temp file regardless of success
 	}()
 
-	scanner := bufio.NewScanner(originalFile)
-	writer := bufio.NewWriter(tempFile)
+	var output io.Writer = tempFile
+	var encoded *transform.Writer
+	if encoder != nil {
+		encoded = transform.NewWriter(tempFile, encoder)
+		output = encoded
+	}
+	writer := bufio.NewWriter(output)
+	state := newFileState(path)
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	rewrite := ns.rewriteLines
+	if ns.Config.Multiline || ns.Config.Scope != scopeAll || ns.Config.Data != dataNone || ns.Config.Markdown != markdownAll || ns.Config.Markup != markupAll || ns.Config.Columns != "" || ns.Config.Anchors {
+		rewrite = ns.rewriteBuffer
+	}
+	if err := rewrite(reader, writer, rules, state); err != nil {
+		return err
+	}
+	if len(state.tally) == 0 && ns.Config.ConvertTo == "" && (ns.Config.EOL == "" || ns.Config.EOL == eolPreserve) {
+		return nil // Nothing changed, so the file is left exactly as it was.
+	}
+	if err := ns.checkLossless(content); err != nil {
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if encoded != nil {
+		if err := encoded.Close(); err != nil {
+			return err
+		}
+	}
+
+	// Ensure the temp file is closed before attempting to rename
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	// Replace the original file with the temp file
+	if err := ns.moveFileWithRetry(tempFile.Name(), path, 6); err != nil {
+		return err
+	}
+
+	ns.recordTally(state)
+	return nil
+}
+
+// rewriteLines applies the rules to the file one line at a time, so a match can never span a line break.
+// Each line is written back with the line ending it was read with, "\r\n" or "\n", unless -eol converts
+// it, and a last line without one stays without one. A byte order mark is kept out of the rules' reach.
+func (ns *NameShifter) rewriteLines(reader io.Reader, writer *bufio.Writer, rules []*Rule, state *fileState) error {
+	lines := bufio.NewReader(reader)
+	for state.line = 1; ; state.line++ {
+		read, err := lines.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if read == "" {
+			return nil
+		}
+		line, ending := splitLineEnding(read)
+		if state.line == 1 && strings.HasPrefix(line, byteOrderMark) {
+			if _, err := writer.WriteString(byteOrderMark); err != nil {
+				return err
+			}
+			line = line[len(byteOrderMark):]
+		}
 
-		modifiedLine := ns.replaceString(line, theStringToBeReplaced, theReplacementString)
+		modifiedLine := line
+		if ns.address == nil || ns.address.allows(line, state) {
+			modifiedLine = ns.applyRules(line, rules, state)
+		}
 
 		//if modifiedLine != line {
 		//	fmt.Printf("Original: %s\n", line)
 		//	fmt.Printf("Modified: %s\n", modifiedLine)
 		//}
-		if _, err := writer.WriteString(modifiedLine + "\n"); err != nil {
-			ns.Context.AddError()
+		if _, err := writer.WriteString(modifiedLine + ns.lineEnding(ending)); err != nil {
 			return err
 		}
-		if modifiedLine != line {
-			ns.Context.AddReplacement() // Corrected to use the context's method for incrementing
+		if err == io.EOF {
+			return nil
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		ns.Context.AddError()
+}
+
+// rewriteBuffer reads the whole file and applies the rules to the parts of it the scope and line address
+// leave eligible. With -multiline each of those parts is matched as a whole, so patterns may span lines,
+// e.g. license headers, import blocks or regexes containing \n; otherwise they're matched line by line.
+// The file is written back exactly as read apart from the matches, and the line endings -eol converts.
+func (ns *NameShifter) rewriteBuffer(reader io.Reader, writer *bufio.Writer, rules []*Rule, state *fileState) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
 		return err
 	}
+	// Like rewriteLines, keep a byte order mark out of the rules' reach, so a "^" pattern can't match or
+	// remove it.
+	text, bom := string(content), ""
+	if strings.HasPrefix(text, byteOrderMark) {
+		text, bom = text[len(byteOrderMark):], byteOrderMark
+	}
 
-	if err := writer.Flush(); err != nil {
-		ns.Context.AddError()
-		return err
+	var rewritten string
+	switch {
+	case ns.Config.Data != dataNone:
+		if rewritten, err = ns.rewriteData(text, state, rules); err != nil {
+			return err
+		}
+	case ns.Config.Markup != markupAll:
+		rewritten = ns.rewriteMarkup(text, state, rules)
+	case ns.Config.Columns != "":
+		if rewritten, err = ns.rewriteColumns(text, state, rules); err != nil {
+			return err
+		}
+	default:
+		spans, err := ns.scopeSpans(state.path, text)
+		if err != nil {
+			return err
+		}
+		spans = intersectSpans(spans, ns.addressSpans(text, state))
+		if !ns.Config.Multiline {
+			spans = splitSpansAtLines(text, spans)
+		}
+		rewritten = ns.rewriteSpans(text, spans, rules, state)
+		if ns.Config.Anchors && isMarkdownFile(state.path) {
+			ns.recordAnchorRenames(state.path, text, rewritten)
+		}
 	}
+	_, err = writer.WriteString(bom + ns.convertLineEndings(rewritten))
+	return err
+}
 
-	// Ensure the temp file is closed before attempting to rename
-	if err := tempFile.Close(); err != nil {
-		ns.Context.AddError()
+// writeFileContent replaces the content of path the way processFile does, through a temp file.
+func (ns *NameShifter) writeFileContent(path string, content []byte) error {
+	tempFile, err := os.CreateTemp("", "nsh_temp_file_")
+	if err != nil {
 		return err
 	}
+	defer os.Remove(tempFile.Name()) // Cleanup temp file regardless of success
 
-	// Replace the original file with the temp file
-	if err := ns.moveFileWithRetry(tempFile.Name(), path, 6); err != nil {
-		ns.Context.AddError()
+	if _, err := tempFile.Write(content); err != nil {
+		tempFile.Close()
 		return err
 	}
-
-	return nil
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return ns.moveFileWithRetry(tempFile.Name(), path, 6)
 }
 
 // moveFile handles moving a file from src to dst, working across different file systems/devices.
@@ -347,8 +903,7 @@ func (ns *NameShifter) shouldProcessFile(path string, info os.FileInfo) bool {
 	return false
 }
 
-
-func (ns *NameShifter) processPath(path string, info os.FileInfo, theStringToBeReplaced, theReplacementString string, cfg *Config) error {
+func (ns *NameShifter) processPath(path string, info os.FileInfo, rules []*Rule, cfg *Config) error {
 	if err := ns.ignoreConfigDirs(path, nil); err != nil {
 		// Uncomment the below if you want the reporter to report failure for skipping config files.
 		//row := []table.Row{{"Path", path, "Error", err}}
@@ -358,8 +913,8 @@ func (ns *NameShifter) processPath(path string, info os.FileInfo, theStringToBeR
 	}
 
 	// Directly use the newly abstracted renameEntity function for files and directories.
-	if cfg.WorkGlobally && (info.IsDir() || strings.Contains(info.Name(), theStringToBeReplaced)) {
-		if err := ns.renameEntity(path, theStringToBeReplaced, theReplacementString); err != nil {
+	if cfg.WorkGlobally && (info.IsDir() || ns.containsMatch(info.Name(), rules)) {
+		if err := ns.renameEntity(path, rules); err != nil {
 			row := []table.Row{{"Path", path, "Error", fmt.Sprintf("Could not rename: %v", err)}}
 			ns.Context.AddError()
 			ns.Context.AddErrorReportRow(row)
@@ -369,15 +924,30 @@ func (ns *NameShifter) processPath(path string, info os.FileInfo, theStringToBeR
 
 	// For files, check if they should be processed and then process.
 	if ns.shouldProcessFile(path, info) {
-		return ns.processFile(path, theStringToBeReplaced, theReplacementString)
+		return ns.processFile(path, rules)
 	}
 
 	return nil
 }
 
-func (ns *NameShifter) renameEntity(entityPath, theStringToBeReplaced, theReplacementString string) error {
-	// Prepare the new path by replacing the specified string.
-	newPath := strings.Replace(entityPath, theStringToBeReplaced, theReplacementString, -1)
+func (ns *NameShifter) renameEntity(entityPath string, rules []*Rule) error {
+	// Prepare the new path by replacing the specified string in the entity's own name only,
+	// so parent directories are left to their own walk entries.
+	oldName := filepath.Base(entityPath)
+	rules = ns.rulesFor(entityPath, rules)
+	state := newFileState(entityPath)
+	newName := ns.applyRules(oldName, rules, state)
+	if newName == oldName {
+		return nil // Nothing to rename.
+	}
+	newPath := filepath.Join(filepath.Dir(entityPath), newName)
+
+	// A plain rename handles files and directories alike, copying is only needed across devices.
+	if err := os.Rename(entityPath, newPath); err == nil {
+		ns.recordRename(entityPath, newPath)
+		ns.recordTally(state)
+		return nil
+	}
 
 	// Attempt to rename (move) the entity.
 	if err := ns.moveFileWithRetry(entityPath, newPath, 6); err != nil {
@@ -385,32 +955,101 @@ func (ns *NameShifter) renameEntity(entityPath, theStringToBeReplaced, theReplac
 		if os.IsPermission(err) {
 			// Try changing permissions and retry the move.
 			if permErr := os.Chmod(entityPath, 0666); permErr != nil {
-				ns.Context.AddError()
 				// Wrap the error to provide more context.
 				return fmt.Errorf("failed to change permissions for %s: %w", entityPath, permErr)
 			}
 			// Retry the move operation.
 			if retryErr := ns.moveFileWithRetry(entityPath, newPath, 6); retryErr != nil {
-				ns.Context.AddError()
 				// Wrap the error to provide more context.
 				return fmt.Errorf("failed to move %s after changing permissions: %w", entityPath, retryErr)
 			}
 		} else {
-			ns.Context.AddError()
 			// Wrap the error to provide more context.
 			return fmt.Errorf("failed to move %s: %w", entityPath, err)
 		}
 	}
 
 	// Log the successful replacement.
-	ns.Context.AddReplacement()
+	ns.recordRename(entityPath, newPath)
+	ns.recordTally(state)
 	return nil
 }
 
+// recordRename remembers that an entity moved from oldPath to newPath, for currentPath.
+func (ns *NameShifter) recordRename(oldPath, newPath string) {
+	oldAbs, err := filepath.Abs(oldPath)
+	if err != nil {
+		return
+	}
+	newAbs, err := filepath.Abs(newPath)
+	if err != nil {
+		return
+	}
+
+	ns.renameMu.Lock()
+	defer ns.renameMu.Unlock()
+	if ns.renames == nil {
+		ns.renames = make(map[string]string)
+	}
+	ns.renames[oldAbs] = newAbs
+}
+
+// currentPath returns where the entity at the absolute path abs, as it was before any renaming, is now.
+// Entities are renamed deepest first, so every rename is recorded with the path its parent had at the
+// start, and abs is followed one path element at a time.
+func (ns *NameShifter) currentPath(abs string) string {
+	ns.renameMu.Lock()
+	defer ns.renameMu.Unlock()
+	if len(ns.renames) == 0 {
+		return abs
+	}
+
+	volume := filepath.VolumeName(abs)
+	old, current := volume+string(filepath.Separator), volume+string(filepath.Separator)
+	for _, name := range strings.Split(strings.TrimPrefix(abs[len(volume):], string(filepath.Separator)), string(filepath.Separator)) {
+		old = filepath.Join(old, name)
+		if renamed, ok := ns.renames[old]; ok {
+			name = filepath.Base(renamed)
+		}
+		current = filepath.Join(current, name)
+	}
+	return current
+}
+
+// configure builds the rules from the command line and checks the flags before anything is replaced,
+// returning the first problem found.
+func (ns *NameShifter) configure() ([]*Rule, error) {
+	rules, err := ns.buildRules(ns.Config.Args)
+	if err != nil {
+		return nil, err
+	}
+	if ns.address, err = newLineAddress(ns.Config); err != nil {
+		return nil, err
+	}
+	validators := []func(*Config) error{
+		validateLimits,
+		func(cfg *Config) error { return validateScope(cfg.Scope) },
+		validateData,
+		validateMarkdown,
+		validateMarkup,
+		validateColumns,
+		validateNormalize,
+		validateBinary,
+		validateEncoding,
+		validateEOL,
+		func(cfg *Config) error { return validateFuzzy(cfg, rules) },
+	}
+	for _, validate := range validators {
+		if err := validate(ns.Config); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
 func main() {
 	resetColors()
 	printLogo()
-	flag.Parse()
 
 	customFlagParsing() // Ensure custom flag parsing is called if not already handled by flag.Parse()
 
@@ -423,13 +1062,42 @@ func main() {
 		os.Exit(0)
 	}
 
-	if len(flag.Args()) < 3 {
+	if len(cfg.Args) > 0 && (cfg.Args[0] == "go-rename" || cfg.Args[0] == "go-module") {
+		run := ns.goRename
+		if cfg.Args[0] == "go-module" {
+			run = ns.migrateModulePath
+		}
+		if err := run(cfg.Args[1:]); err != nil {
+			if ctx.errorsCount > 0 {
+				ctx.DisplayErrorReport()
+			}
+			color.Red(fmt.Sprintf("\n> %v ❌", err))
+			os.Exit(1)
+		}
+		if ctx.errorsCount > 0 {
+			ctx.DisplayErrorReport()
+		}
+		ctx.ReplacementsAndErrorsReport()
+		os.Exit(0)
+	}
+
+	if len(cfg.Args) < 3 && !(len(cfg.Args) >= 1 && cfg.RulesFile != "") {
 		color.Red(fmt.Sprintf("\n> Usage: go run nsh.go <startingDirectory> <theStringToBeReplaced> <theReplacementString> -flags❗📚👀"))
+		color.Red(fmt.Sprintf("> Or: go run nsh.go <startingDirectory> -rules=<rulesFile> -flags❗📚👀"))
 		os.Exit(1)
 	}
 
-	args := flag.Args()
-	startingDirectory, theStringToBeReplaced, theReplacementString := args[0], args[1], args[2]
+	startingDirectory := cfg.Args[0]
+	rules, err := ns.configure()
+	if err != nil {
+		color.Red(fmt.Sprintf("\n> %v ❌", err))
+		os.Exit(1)
+	}
+	for _, ext := range cfg.FileExtensions {
+		if cfg.Scope != scopeAll && !ns.canScope("file"+ext) {
+			color.Yellow(fmt.Sprintf("\n> No lexer profile for %s files, they'll be left alone while -scope is set ⚠️", ext))
+		}
+	}
 	//fmt.Println("> Starting directory:", startingDirectory)
 	paths, err := ns.collectPaths(startingDirectory)
 	//fmt.Println("> Paths:", paths)
@@ -438,12 +1106,33 @@ func main() {
 		os.Exit(1)
 	}
 
-	ns.ProcessAllPaths(paths, theStringToBeReplaced, theReplacementString)
+	if cfg.Fuzzy > 0 {
+		misses := ns.findNearMisses(paths, rules)
+		ctx.NearMissReport(misses)
+		accepted, unknown := ns.acceptNearMisses(misses)
+		for _, variant := range unknown {
+			color.Yellow(fmt.Sprintf("\n> %q isn't one of the near misses, it won't be replaced ⚠️", variant))
+		}
+		if cfg.Accept == "" {
+			color.Yellow("\n> Nothing was replaced, pass -accept with the near misses to replace, or 'all' 🤏")
+			os.Exit(0)
+		}
+		rules = append(rules, accepted...)
+	}
+
+	ns.ProcessAllPaths(paths, rules)
 
 	if ctx.errorsCount > 0 {
 		ctx.DisplayErrorReport()
 	}
 
+	if len(rules) > 1 {
+		ctx.RuleReport(rules)
+	}
+	if cfg.Binary {
+		ctx.PatchReport()
+	}
+
 	ctx.ReplacementsAndErrorsReport()
 	os.Exit(0)
 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplaceStringRegexCaptureGroups(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true}, NewAppContext())
+
+	tests := []struct {
+		name, line, search, replacement, want string
+	}{
+		{"numbered group", "v1 and v22", `v(\d+)`, "version-$1", "version-1 and version-22"},
+		{"named group", "user_id", `(?P<noun>\w+)_id`, "${noun}ID", "userID"},
+		{"group followed by letters", "foo.bar", `(\w+)\.(\w+)`, "${2}_$1", "bar_foo"},
+		{"no match", "nothing here", `v(\d+)`, "version-$1", "nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceAll(ns, tt.line, tt.search, tt.replacement); got != tt.want {
+				t.Errorf("replaceString(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceStringLiteralModeIgnoresMetacharacters(t *testing.T) {
+	// Without -regex a dot is a dot and a dollar in the replacement is kept as written.
+	ns := NewNameShifter(&Config{CaseMatching: false}, NewAppContext())
+
+	if got := replaceAll(ns, "a.b axb A.B", "a.b", "$1.c"); got != "$1.c axb $1.c" {
+		t.Errorf("replaceString = %q", got)
+	}
+}
+
+func TestReplaceStringRegexHonoursCaseMatching(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, Regex: true}, NewAppContext())
+
+	if got := replaceAll(ns, "Color colour COLOR", `colou?r`, "hue"); got != "hue hue hue" {
+		t.Errorf("replaceString = %q", got)
+	}
+}
+
+func TestCompilePatternRejectsInvalidRegex(t *testing.T) {
+	ns := NewNameShifter(&Config{Regex: true}, NewAppContext())
+
+	rule := ns.newRule(`v(\d+`, "v$1")
+	if _, err := ns.compilePattern(rule); err == nil {
+		t.Fatal("compilePattern accepted an unbalanced group")
+	}
+	if ns.containsMatch("v1", []*Rule{rule}) {
+		t.Error("containsMatch matched with an invalid pattern")
+	}
+}
+
+func TestProcessFileRegex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.md")
+	if err := os.WriteFile(path, []byte("release v1\nrelease v2\nunchanged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true}, NewAppContext())
+	if err := ns.processFile(path, []*Rule{ns.newRule(`release v(\d)`, "shipped in $1.0")}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "shipped in 1.0\nshipped in 2.0\nunchanged\n"; string(got) != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if ns.Context.replacementsCount != 2 {
+		t.Errorf("replacements = %d, want 2", ns.Context.replacementsCount)
+	}
+}
+
+func TestProcessSinglePathReportsErrorsOnce(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.go")
+	if err := os.WriteFile(broken, []byte("package p\nvar s = \"open\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := NewNameShifter(&Config{Scope: scopeStrings, FileExtensions: []string{".go"}}, NewAppContext())
+	rules := []*Rule{ns.newRule("open", "shut")}
+	ns.processSinglePath(broken, rules)
+	ns.processSinglePath(filepath.Join(dir, "missing.go"), rules)
+
+	if ns.Context.errorsCount != 2 {
+		t.Errorf("errors = %d, want one per path", ns.Context.errorsCount)
+	}
+	report := ns.Context.errorReport.Render()
+	for _, want := range []string{"could not tell comments and strings apart", "missing.go"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("the error report doesn't mention %q:\n%s", want, report)
+		}
+	}
+	if n := strings.Count(report, broken); n != 1 {
+		t.Errorf("the error report names %s %d times, want once:\n%s", broken, n, report)
+	}
+}
+
+func TestProcessAllPathsRenamesDeepestFirst(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		root := writeTree(t, map[string]string{
+			"acme/acme.go":           "package acme\n",
+			"acme/sub/acme.md":       "acme docs\n",
+			"acme/sub/deep/acme.txt": "acme\n",
+		})
+
+		ns := NewNameShifter(&Config{CaseMatching: true, WorkGlobally: true, ConcurrentRun: concurrent, FileExtensions: []string{".go", ".md"}}, NewAppContext())
+		paths, err := ns.collectPaths(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns.ProcessAllPaths(paths, []*Rule{ns.newRule("acme", "globex")})
+
+		for name, want := range map[string]string{
+			"globex/globex.go":           "package globex\n",
+			"globex/sub/globex.md":       "globex docs\n",
+			"globex/sub/deep/globex.txt": "acme\n", // Renamed, but .txt contents aren't processed.
+		} {
+			if got := readTree(t, root, name); got != want {
+				t.Errorf("concurrent=%v: %s = %q, want %q", concurrent, name, got, want)
+			}
+		}
+		if ns.Context.errorsCount != 0 {
+			t.Errorf("concurrent=%v: %d errors:\n%s", concurrent, ns.Context.errorsCount, ns.Context.errorReport.Render())
+		}
+	}
+}
+
+func TestConfigureChecksEveryFlag(t *testing.T) {
+	valid := Config{Args: []string{".", "old", "new"}, CaseMatching: true, NthScope: "file", EOL: eolPreserve}
+	ns := NewNameShifter(&valid, NewAppContext())
+	if rules, err := ns.configure(); err != nil || len(rules) != 1 {
+		t.Fatalf("configure = %v, %v, want the old → new rule", rules, err)
+	}
+
+	tests := map[string]func(cfg *Config){
+		"a line address": func(cfg *Config) { cfg.LineRanges = "x-y" },
+		"-scope":         func(cfg *Config) { cfg.Scope = "everything" },
+		"-data":          func(cfg *Config) { cfg.Data = "both" },
+		"-encoding":      func(cfg *Config) { cfg.Encoding = "klingon" },
+		"-eol":           func(cfg *Config) { cfg.EOL = "cr" },
+		"-fuzzy":         func(cfg *Config) { cfg.Fuzzy = -1 },
+	}
+	for name, mistake := range tests {
+		cfg := valid
+		mistake(&cfg)
+		if _, err := NewNameShifter(&cfg, NewAppContext()).configure(); err == nil {
+			t.Errorf("configure accepted a bad %s", name)
+		}
+	}
+}
+
+func TestParseInterspersedFlags(t *testing.T) {
+	saved := flag.CommandLine
+	defer func() { flag.CommandLine = saved }()
+	flag.CommandLine = flag.NewFlagSet("nsh", flag.ContinueOnError)
+	global := flag.Bool("g", false, "")
+	extensions := flag.String("ext", "", "")
+
+	args := parseInterspersedFlags([]string{"dir", "-g", "old", "-ext=.sh", "new", "--", "-x", "-g"})
+
+	if want := []string{"dir", "old", "new", "-x", "-g"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("positional arguments = %q, want %q", args, want)
+	}
+	if !*global || *extensions != ".sh" {
+		t.Errorf("flags before -- weren't parsed: g=%v ext=%q", *global, *extensions)
+	}
+}
+
+func TestApplyRulesSimultaneously(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules [][2]string
+		text  string
+		want  string
+		tally []int
+	}{
+		{"swap", [][2]string{{"a", "b"}, {"b", "a"}}, "a b ab", "b a ba", []int{2, 2}},
+		{"no chaining", [][2]string{{"cat", "dog"}, {"dog", "wolf"}}, "cat dog", "dog wolf", []int{1, 1}},
+		{"leftmost match wins", [][2]string{{"bc", "X"}, {"abc", "Y"}}, "abcd", "Yd", []int{0, 1}},
+		{"earlier rule wins a tie", [][2]string{{"ab", "X"}, {"abc", "Y"}}, "abc", "Xc", []int{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := NewNameShifter(&Config{CaseMatching: true, Simultaneous: true}, NewAppContext())
+			var rules []*Rule
+			for _, pair := range tt.rules {
+				rules = append(rules, ns.newRule(pair[0], pair[1]))
+			}
+			state := newFileState("")
+			if got := ns.applyRules(tt.text, rules, state); got != tt.want {
+				t.Errorf("applyRules(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			tally := make([]int, len(rules))
+			for i, rule := range rules {
+				tally[i] = state.tally[rule]
+			}
+			if !reflect.DeepEqual(tally, tt.tally) {
+				t.Errorf("tally = %v, want %v", tally, tt.tally)
+			}
+		})
+	}
+}
+
+func TestApplyRulesSequentiallyChains(t *testing.T) {
+	// Without -simultaneous every rule sees the output of the ones before it.
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	rules := []*Rule{ns.newRule("cat", "dog"), ns.newRule("dog", "wolf")}
+
+	if got := ns.applyRules("cat dog", rules, newFileState("")); got != "wolf wolf" {
+		t.Errorf("got %q", got)
+	}
+}
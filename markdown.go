@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"golang.org/x/text/transform"
+)
+
+// Parts of Markdown files -markdown narrows replacements down to.
+const (
+	markdownAll   = ""
+	markdownProse = "prose"
+	markdownCode  = "code"
+	markdownLinks = "links"
+)
+
+// markdownRegions are the parts of a Markdown document that aren't prose.
+type markdownRegions struct {
+	code  []span // Contents of fenced code blocks and inline code spans.
+	links []span // Link and image destinations, reference definitions and autolinks.
+	other []span // Fence lines, HTML comments and front matter, which belong to none of the parts.
+}
+
+// validateMarkdown checks the -markdown flag before anything is replaced.
+func validateMarkdown(cfg *Config) error {
+	switch cfg.Markdown {
+	case markdownAll, markdownProse, markdownCode, markdownLinks:
+	default:
+		return fmt.Errorf("invalid -markdown %q, expected \"prose\", \"code\" or \"links\"", cfg.Markdown)
+	}
+	if cfg.Markdown != markdownAll && (cfg.Scope != scopeAll || cfg.Data != dataNone) {
+		return errors.New("-markdown can't be combined with -scope or -data")
+	}
+	return nil
+}
+
+// isMarkdownFile reports whether path is a Markdown file.
+func isMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// markdownSpans returns the parts of a Markdown document the -markdown flag selects.
+func (ns *NameShifter) markdownSpans(content string) []span {
+	regions := markdownRegionsOf(content)
+	switch ns.Config.Markdown {
+	case markdownCode:
+		return regions.code
+	case markdownLinks:
+		return regions.links
+	}
+	notProse := mergeSpans(mergeSpans(regions.code, regions.links), regions.other)
+	return complementSpans(notProse, len(content))
+}
+
+var (
+	// markdownFence matches the opening or closing line of a fenced code block.
+	markdownFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	// markdownReference matches a link reference definition, capturing its destination.
+	markdownReference = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:[ \t]*(<[^>\n]*>|\S+)`)
+	// markdownAutolink matches <scheme:...> and <user@host> autolinks.
+	markdownAutolink = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*|[^\s<>@]+@[^\s<>@]+)>`)
+)
+
+// markdownRegionsOf splits a Markdown document into code, link destinations and the rest. It follows
+// CommonMark closely enough for renames: fenced blocks, backtick code spans, inline links and images,
+// reference definitions and autolinks are recognised; indented code blocks aren't, since they can't be
+// told apart from indented list content without a full parser.
+func markdownRegionsOf(content string) markdownRegions {
+	var regions markdownRegions
+	start := frontMatterEnd(content)
+	if start > 0 {
+		regions.other = append(regions.other, span{0, start})
+	}
+
+	var fence string // Backticks or tildes of the open fence, if any.
+	inline := start  // Start of the text since the last fence, scanned for inline code and links.
+	offset := start
+	for _, line := range strings.SplitAfter(content[start:], "\n") {
+		lineStart := offset
+		offset += len(line)
+		text := strings.TrimRight(line, "\r\n")
+		marker := markdownFence.FindStringSubmatch(text)
+
+		if fence != "" {
+			closes := marker != nil && marker[1][0] == fence[0] && len(marker[1]) >= len(fence) &&
+				strings.TrimSpace(text[len(marker[0]):]) == ""
+			if closes {
+				regions.other = append(regions.other, span{lineStart, offset})
+				fence, inline = "", offset
+			} else if text != "" {
+				regions.code = append(regions.code, span{lineStart, lineStart + len(text)})
+			}
+			continue
+		}
+		if marker != nil && !(marker[1][0] == '`' && strings.Contains(text[len(marker[0]):], "`")) {
+			regions.scanInline(content, inline, lineStart)
+			regions.other = append(regions.other, span{lineStart, offset})
+			fence, inline = marker[1], offset
+		}
+	}
+	if fence == "" {
+		regions.scanInline(content, inline, len(content))
+	}
+	return regions
+}
+
+// frontMatterEnd returns where the YAML front matter at the top of a Markdown document ends, or 0.
+func frontMatterEnd(content string) int {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) == 0 || strings.TrimRight(lines[0], "\r\n") != "---" {
+		return 0
+	}
+	end := len(lines[0])
+	for _, line := range lines[1:] {
+		end += len(line)
+		if trimmed := strings.TrimRight(line, "\r\n"); trimmed == "---" || trimmed == "..." {
+			return end
+		}
+	}
+	return 0 // Without a closing line it's a horizontal rule.
+}
+
+// scanInline finds code spans, link destinations and HTML comments in content[start:end], which holds
+// no fenced code.
+func (regions *markdownRegions) scanInline(content string, start, end int) {
+	text := content[start:end]
+	references := markdownReference.FindAllStringSubmatchIndex(text, -1)
+
+	for i := 0; i < len(text); {
+		for len(references) > 0 && references[0][2] < i {
+			references = references[1:] // Inside a code span or comment.
+		}
+		if len(references) > 0 && i == references[0][2] {
+			destination := span{start + references[0][2], start + references[0][3]}
+			if text[references[0][2]] == '<' {
+				destination = span{destination.start + 1, destination.end - 1}
+			}
+			regions.links = append(regions.links, destination)
+			i, references = references[0][1], references[1:]
+			continue
+		}
+
+		switch {
+		case text[i] == '\\':
+			i += 2
+		case text[i] == '`':
+			run := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			closing := findBacktickRun(text, i+run, run)
+			if closing < 0 {
+				i += run // Unmatched backticks are literal.
+				continue
+			}
+			regions.code = append(regions.code, span{start + i + run, start + closing})
+			i = closing + run
+		case strings.HasPrefix(text[i:], "<!--"):
+			closing := strings.Index(text[i:], "-->")
+			if closing < 0 {
+				closing = len(text) - i - 3
+			}
+			regions.other = append(regions.other, span{start + i, start + i + closing + 3})
+			i += closing + 3
+		case text[i] == '<' && markdownAutolink.MatchString(text[i:]):
+			match := markdownAutolink.FindStringSubmatchIndex(text[i:])
+			regions.links = append(regions.links, span{start + i + match[2], start + i + match[3]})
+			i += match[1]
+		case strings.HasPrefix(text[i:], "]("):
+			from, to := linkDestination(text, i+2)
+			if to > from {
+				regions.links = append(regions.links, span{start + from, start + to})
+			}
+			i = to
+		default:
+			i++
+		}
+	}
+}
+
+// findBacktickRun returns the offset of the next run of exactly n backticks in text from offset on, or -1.
+func findBacktickRun(text string, offset, n int) int {
+	for i := offset; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// linkDestination returns the destination of an inline link whose parenthesis opened just before
+// offset, without any surrounding <>, stopping at whitespace or the unbalanced closing parenthesis.
+func linkDestination(text string, offset int) (int, int) {
+	for offset < len(text) && (text[offset] == ' ' || text[offset] == '\t') {
+		offset++
+	}
+	if offset < len(text) && text[offset] == '<' {
+		closing := strings.IndexAny(text[offset:], ">\n")
+		if closing < 0 || text[offset+closing] != '>' {
+			return offset, offset
+		}
+		return offset + 1, offset + closing
+	}
+	depth := 0
+	end := offset
+	for ; end < len(text); end++ {
+		c := text[end]
+		if c == '\\' {
+			end++
+			continue
+		}
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			break
+		}
+	}
+	return offset, min(end, len(text))
+}
+
+// markdownHeading matches ATX headings, capturing their text without closing hashes.
+var markdownHeading = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+
+// markdownSetext matches the underline of a setext heading.
+var markdownSetext = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+
+// markdownInlineLink matches inline links and images, to take their text for anchors.
+var markdownInlineLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// headingAnchors returns the anchors GitHub generates for the headings of a Markdown document, in order.
+func headingAnchors(content string) []string {
+	var anchors []string
+	seen := make(map[string]int)
+	regions := markdownRegionsOf(content)
+	skip := mergeSpans(regions.code, regions.other)
+	offset := 0
+	previous := ""
+	for _, line := range strings.SplitAfter(content, "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		lineStart := offset
+		offset += len(line)
+		if spansContain(skip, lineStart) {
+			previous = ""
+			continue
+		}
+
+		heading, ok := "", false
+		if match := markdownHeading.FindStringSubmatch(text); match != nil {
+			heading, ok = match[1], true
+		} else if markdownSetext.MatchString(text) && strings.TrimSpace(previous) != "" && !markdownHeading.MatchString(previous) {
+			heading, ok = strings.TrimSpace(previous), true
+		}
+		previous = text
+		if !ok {
+			continue
+		}
+
+		anchor := slugify(heading)
+		if n := seen[anchor]; n > 0 {
+			seen[anchor]++
+			anchor += "-" + strconv.Itoa(n)
+		} else {
+			seen[anchor] = 1
+		}
+		anchors = append(anchors, anchor)
+	}
+	return anchors
+}
+
+// slugify turns heading text into an anchor the way GitHub does: markup and punctuation go, letters are
+// lower cased and spaces become hyphens.
+func slugify(heading string) string {
+	heading = markdownInlineLink.ReplaceAllString(heading, "$1")
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// recordAnchorRenames compares the headings of a Markdown file before and after replacing, and remembers
+// the anchors that changed so links to them can follow.
+func (ns *NameShifter) recordAnchorRenames(path, before, after string) {
+	old, renamed := headingAnchors(before), headingAnchors(after)
+	if len(old) != len(renamed) {
+		return // A replacement made or unmade a heading; there's no telling which is which.
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	ns.anchorMu.Lock()
+	defer ns.anchorMu.Unlock()
+	for i := range old {
+		if old[i] == renamed[i] {
+			continue
+		}
+		if ns.anchorRenames == nil {
+			ns.anchorRenames = make(map[string]map[string]string)
+		}
+		if ns.anchorRenames[abs] == nil {
+			ns.anchorRenames[abs] = make(map[string]string)
+		}
+		ns.anchorRenames[abs][old[i]] = renamed[i]
+	}
+}
+
+// updateAnchorLinks rewrites the #fragments of links, in every Markdown file among paths, that point
+// at a heading a replacement renamed, in the same file or another one. Files are found, and links
+// resolved, where they are after -work-globally renamed them.
+func (ns *NameShifter) updateAnchorLinks(paths []string) {
+	if len(ns.anchorRenames) == 0 {
+		return
+	}
+	anchors := make(map[string]map[string]string, len(ns.anchorRenames))
+	for file, renames := range ns.anchorRenames {
+		anchors[ns.currentPath(file)] = renames
+	}
+
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		path = ns.currentPath(abs)
+		if !isMarkdownFile(path) {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !ns.shouldProcessFile(path, info) {
+			continue
+		}
+		if err := ns.updateAnchorLinksIn(path, anchors); err != nil {
+			ns.Context.AddError()
+			ns.Context.AddErrorReportRow([]table.Row{{"Path", path, "Error", err.Error()}})
+		}
+	}
+}
+
+// updateAnchorLinksIn rewrites the renamed anchors linked to from a single Markdown file, given by its
+// absolute path, decoding and encoding it the way processFile does.
+func (ns *NameShifter) updateAnchorLinksIn(path string, anchors map[string]map[string]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder, encoder := ns.fileTransforms(content)
+	decoded := content
+	if decoder != nil {
+		if decoded, _, err = transform.Bytes(decoder, content); err != nil {
+			return err
+		}
+	}
+	text := string(decoded)
+
+	var b strings.Builder
+	last, count := 0, 0
+	for _, link := range markdownRegionsOf(text).links {
+		destination := text[link.start:link.end]
+		target, fragment, ok := strings.Cut(destination, "#")
+		if !ok || strings.Contains(target, "://") {
+			continue
+		}
+		file := path
+		if target != "" {
+			unescaped, err := url.PathUnescape(target)
+			if err != nil {
+				continue
+			}
+			file = filepath.Join(filepath.Dir(path), filepath.FromSlash(unescaped))
+		}
+		renamed, ok := anchors[file][fragment]
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:link.start])
+		b.WriteString(target + "#" + renamed)
+		last = link.end
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := ns.checkLossless(content); err != nil {
+		return err
+	}
+	b.WriteString(text[last:])
+
+	rewritten := []byte(b.String())
+	if encoder != nil {
+		if rewritten, _, err = transform.Bytes(encoder, rewritten); err != nil {
+			return err
+		}
+	}
+	if err := ns.writeFileContent(path, rewritten); err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		ns.Context.AddReplacement()
+	}
+	return nil
+}
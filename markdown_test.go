@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+const markdownDoc = "---\ntitle: Widget\n---\n# Widget guide\n\nCall `Widget()` to build a Widget, see [Widget docs](https://example.com/Widget).\n\n```go\nw := Widget()\n```\n\n<!-- Widget -->\n[ref]: ./Widget.md\n<https://Widget.dev>\n"
+
+func TestMarkdownScopes(t *testing.T) {
+	tests := map[string]string{
+		markdownProse: "---\ntitle: Widget\n---\n# Gadget guide\n\nCall `Widget()` to build a Gadget, see [Gadget docs](https://example.com/Widget).\n\n```go\nw := Widget()\n```\n\n<!-- Widget -->\n[ref]: ./Widget.md\n<https://Widget.dev>\n",
+		markdownCode:  "---\ntitle: Widget\n---\n# Widget guide\n\nCall `Gadget()` to build a Widget, see [Widget docs](https://example.com/Widget).\n\n```go\nw := Gadget()\n```\n\n<!-- Widget -->\n[ref]: ./Widget.md\n<https://Widget.dev>\n",
+		markdownLinks: "---\ntitle: Widget\n---\n# Widget guide\n\nCall `Widget()` to build a Widget, see [Widget docs](https://example.com/Gadget).\n\n```go\nw := Widget()\n```\n\n<!-- Widget -->\n[ref]: ./Gadget.md\n<https://Gadget.dev>\n",
+	}
+	for scope, want := range tests {
+		t.Run(scope, func(t *testing.T) {
+			ns := NewNameShifter(&Config{CaseMatching: true, Markdown: scope}, NewAppContext())
+			if got := shiftFile(t, ns, "guide.md", markdownDoc, ns.newRule("Widget", "Gadget")); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMarkdownCodeSpansAndFences(t *testing.T) {
+	// A double backtick span may hold single backticks; a fence only closes with a run at least as long.
+	doc := "``a `x` b`` x\n````\nx\n```\nx\n````\nx\n"
+	regions := markdownRegionsOf(doc)
+
+	var code []string
+	for _, s := range regions.code {
+		code = append(code, doc[s.start:s.end])
+	}
+	if want := []string{"a `x` b", "x", "```", "x"}; !reflect.DeepEqual(code, want) {
+		t.Errorf("code = %q, want %q", code, want)
+	}
+}
+
+func TestMarkdownLeavesOtherFilesAlone(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markdown: markdownProse}, NewAppContext())
+
+	if got := shiftFile(t, ns, "main.go", "// Widget\n", ns.newRule("Widget", "Gadget")); got != "// Widget\n" {
+		t.Errorf("main.go = %q", got)
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	doc := "# Getting Started!\n\nSetup\n=====\n\n## Getting started\n\n```\n# not a heading\n```\n\n### [Install](x.md) `nsh` #\n"
+
+	want := []string{"getting-started", "setup", "getting-started-1", "install-nsh"}
+	if got := headingAnchors(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("headingAnchors = %q, want %q", got, want)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for heading, want := range map[string]string{
+		"What's New in v2.0?":     "whats-new-in-v20",
+		"snake_case & kebab-case": "snake_case--kebab-case",
+		"Überblick":               "überblick",
+	} {
+		if got := slugify(heading); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", heading, got, want)
+		}
+	}
+}
+
+func TestAnchorLinksFollowRenamedHeadings(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"guide.md":      "# Using Widget\n\nSee [below](#using-widget).\n",
+		"docs/index.md": "Read [the guide](../guide.md#using-widget) or [elsewhere](https://example.com/guide.md#using-widget).\n",
+	})
+	ns := NewNameShifter(&Config{CaseMatching: true, Markdown: markdownProse, Anchors: true, FileExtensions: []string{".md"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns.ProcessAllPaths(paths, []*Rule{ns.newRule("Widget", "Gadget")})
+
+	if got, want := readTree(t, root, "guide.md"), "# Using Gadget\n\nSee [below](#using-gadget).\n"; got != want {
+		t.Errorf("guide.md = %q, want %q", got, want)
+	}
+	if got, want := readTree(t, root, "docs/index.md"), "Read [the guide](../guide.md#using-gadget) or [elsewhere](https://example.com/guide.md#using-widget).\n"; got != want {
+		t.Errorf("docs/index.md = %q, want %q", got, want)
+	}
+}
+
+func TestAnchorLinksFollowRenamedFiles(t *testing.T) {
+	// The paths in links are replaced like any other text, the lower case fragments aren't.
+	root := writeTree(t, map[string]string{
+		"Widget/Widget.md": "# Widget setup\n\nSee [above](#widget-setup).\n",
+		"index.md":         "Read [setup](Widget/Widget.md#widget-setup).\n",
+		"notes.markdown":   "Read [setup](Widget/Widget.md#widget-setup).\n", // Not among -ext.
+	})
+	ns := NewNameShifter(&Config{CaseMatching: true, WorkGlobally: true, Anchors: true, FileExtensions: []string{".md"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns.ProcessAllPaths(paths, []*Rule{ns.newRule("Widget", "Gadget")})
+
+	for name, want := range map[string]string{
+		"Gadget/Gadget.md": "# Gadget setup\n\nSee [above](#gadget-setup).\n",
+		"index.md":         "Read [setup](Gadget/Gadget.md#gadget-setup).\n",
+		"notes.markdown":   "Read [setup](Widget/Widget.md#widget-setup).\n",
+	} {
+		if got := readTree(t, root, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if ns.Context.errorsCount != 0 {
+		t.Errorf("%d errors:\n%s", ns.Context.errorsCount, ns.Context.errorReport.Render())
+	}
+}
+
+func TestAnchorLinksInOtherEncodings(t *testing.T) {
+	index := append([]byte{0xff, 0xfe}, utf16(t, "Read [the guide](guide.md#using-widget).\n", unicode.LittleEndian)...)
+	root := writeTree(t, map[string]string{
+		"guide.md": "# Using Widget\n",
+		"index.md": string(index),
+	})
+	ns := NewNameShifter(&Config{CaseMatching: true, Markdown: markdownProse, Anchors: true, FileExtensions: []string{".md"}}, NewAppContext())
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns.ProcessAllPaths(paths, []*Rule{ns.newRule("Widget", "Gadget")})
+
+	want := append([]byte{0xff, 0xfe}, utf16(t, "Read [the guide](guide.md#using-gadget).\n", unicode.LittleEndian)...)
+	if got := readTree(t, root, "index.md"); got != string(want) {
+		t.Errorf("index.md = %q, want %q", got, want)
+	}
+}
+
+func TestValidateMarkdown(t *testing.T) {
+	if err := validateMarkdown(&Config{Markdown: "headings"}); err == nil {
+		t.Error("accepted -markdown=headings")
+	}
+	if err := validateMarkdown(&Config{Markdown: markdownCode, Data: dataValues}); err == nil {
+		t.Error("accepted -markdown combined with -data")
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Parts of XML and HTML files -markup narrows replacements down to.
+const (
+	markupAll        = ""
+	markupElements   = "elements"
+	markupAttributes = "attributes"
+	markupValues     = "values"
+	markupText       = "text"
+)
+
+// validateMarkup checks the -markup flag before anything is replaced. It takes a comma separated list.
+func validateMarkup(cfg *Config) error {
+	if cfg.Markup == markupAll {
+		return nil
+	}
+	for _, part := range strings.Split(cfg.Markup, ",") {
+		switch strings.TrimSpace(part) {
+		case markupElements, markupAttributes, markupValues, markupText:
+		default:
+			return fmt.Errorf("invalid -markup %q, expected \"elements\", \"attributes\", \"values\" or \"text\"", part)
+		}
+	}
+	if cfg.Scope != scopeAll || cfg.Data != dataNone || cfg.Markdown != markdownAll {
+		return errors.New("-markup can't be combined with -scope, -data or -markdown")
+	}
+	return nil
+}
+
+// isMarkupFile reports whether path is an XML or HTML file -markup can take apart.
+func isMarkupFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml", ".html", ".htm", ".xhtml", ".svg", ".xsd", ".xsl", ".xslt", ".wsdl", ".plist", ".rss", ".atom",
+		".csproj", ".vbproj", ".fsproj", ".vcxproj", ".props", ".targets", ".nuspec", ".resx", ".xaml",
+		".config", ".manifest", ".storyboard", ".xib", ".kml", ".gpx":
+		return true
+	}
+	return false
+}
+
+// markupSelects reports whether -markup selects part.
+func (ns *NameShifter) markupSelects(part string) bool {
+	for _, selected := range strings.Split(ns.Config.Markup, ",") {
+		if strings.TrimSpace(selected) == part {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteMarkup applies the rules to the element names, attribute names, attribute values or text
+// nodes -markup selects, one node at a time. Comments, declarations and processing instructions are
+// left alone, and so is every entity reference outside the text a replacement touched.
+func (ns *NameShifter) rewriteMarkup(content string, state *fileState, rules []*Rule) string {
+	var selected []dataNode
+	for _, node := range markupNodes(content) {
+		if ns.markupSelects(node.part) {
+			selected = append(selected, node.dataNode)
+		}
+	}
+	return ns.rewriteNodes(content, selected, rules, state)
+}
+
+// markupNode is an element name, attribute name, attribute value or text node of an XML or HTML file.
+type markupNode struct {
+	dataNode
+	part string
+	// Whether an element name belongs to an end tag, or to a start tag closed with '/>'.
+	closing, selfClosing bool
+}
+
+// markupScanner splits XML and HTML into nodes. It's lenient the way browsers are: unquoted attribute
+// values, stray '<' in text and unclosed tags don't stop it, they're just not taken apart any further.
+type markupScanner struct {
+	content   string
+	pos       int
+	nodes     []markupNode
+	keepSpace bool // Whether text nodes holding only whitespace are nodes too.
+}
+
+// markupRawText are the HTML elements whose content is raw text rather than markup.
+var markupRawText = map[string]bool{"script": true, "style": true}
+
+// markupEntity matches a character or entity reference.
+var markupEntity = regexp.MustCompile(`&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+
+// markupNodes finds the names, attribute values and text nodes of an XML or HTML document, in file order.
+func markupNodes(content string) []markupNode {
+	return scanMarkup(&markupScanner{content: content})
+}
+
+func scanMarkup(s *markupScanner) []markupNode {
+	content := s.content
+	textStart := 0
+	for s.pos < len(content) {
+		i := strings.IndexByte(content[s.pos:], '<')
+		if i < 0 {
+			break
+		}
+		tagStart := s.pos + i
+		s.pos = tagStart
+		if !s.markupAt() {
+			s.pos++ // A stray '<', which is just text.
+			continue
+		}
+		s.addText(textStart, tagStart)
+		s.tag()
+		textStart = s.pos
+	}
+	s.addText(textStart, len(content))
+	return s.nodes
+}
+
+// markupAt reports whether a comment, declaration, processing instruction or tag starts at pos.
+func (s *markupScanner) markupAt() bool {
+	rest := s.content[s.pos+1:]
+	if rest == "" {
+		return false
+	}
+	switch c := rest[0]; {
+	case c == '!', c == '?':
+		return true
+	case c == '/':
+		return len(rest) > 1 && isNameStart(rest[1])
+	default:
+		return isNameStart(c)
+	}
+}
+
+// tag consumes the comment, CDATA section, declaration, processing instruction or tag at pos.
+func (s *markupScanner) tag() {
+	rest := s.content[s.pos:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		s.skipPast("-->", 4)
+	case strings.HasPrefix(rest, "<![CDATA["):
+		start := s.pos + len("<![CDATA[")
+		s.skipPast("]]>", len("<![CDATA["))
+		end := s.pos - len("]]>")
+		if end < start {
+			end = s.pos // Unterminated, it runs to the end of the document.
+		}
+		s.addCDATA(start, end)
+	case strings.HasPrefix(rest, "<?"):
+		s.skipPast("?>", 2)
+	case strings.HasPrefix(rest, "<!"):
+		s.skipPast(">", 2)
+	case strings.HasPrefix(rest, "</"):
+		s.pos += 2
+		if s.addName(markupElements) != "" {
+			s.nodes[len(s.nodes)-1].closing = true
+		}
+		s.skipPast(">", 0)
+	default:
+		s.pos++
+		element := len(s.nodes)
+		name := s.addName(markupElements)
+		selfClosing := s.attributes()
+		if name != "" {
+			s.nodes[element].selfClosing = selfClosing
+		}
+		if tag := strings.ToLower(name); markupRawText[tag] && !selfClosing {
+			s.rawText(tag)
+		}
+	}
+}
+
+// skipPast moves pos past the next occurrence of end, searching from offset bytes after pos.
+func (s *markupScanner) skipPast(end string, offset int) {
+	if i := strings.Index(s.content[s.pos+offset:], end); i >= 0 {
+		s.pos += offset + i + len(end)
+		return
+	}
+	s.pos = len(s.content)
+}
+
+// attributes consumes the attributes of a start tag and its closing '>', reporting whether it was '/>'.
+func (s *markupScanner) attributes() bool {
+	for {
+		s.skipSpace()
+		if s.pos >= len(s.content) {
+			return false
+		}
+		switch s.content[s.pos] {
+		case '>':
+			s.pos++
+			return false
+		case '/':
+			s.pos++
+			if s.pos < len(s.content) && s.content[s.pos] == '>' {
+				s.pos++
+				return true
+			}
+			continue
+		case '<':
+			return false // An unclosed tag, the next one starts here.
+		}
+
+		if s.addName(markupAttributes) == "" {
+			s.pos++ // Something no attribute name starts with, e.g. a stray quote.
+			continue
+		}
+		s.skipSpace()
+		if s.pos >= len(s.content) || s.content[s.pos] != '=' {
+			continue // A boolean attribute.
+		}
+		s.pos++
+		s.skipSpace()
+		s.attributeValue()
+	}
+}
+
+// attributeValue consumes a quoted or unquoted attribute value.
+func (s *markupScanner) attributeValue() {
+	if s.pos >= len(s.content) {
+		return
+	}
+	if quote := s.content[s.pos]; quote == '"' || quote == '\'' {
+		start := s.pos + 1
+		end := strings.IndexByte(s.content[start:], quote)
+		if end < 0 {
+			s.pos = len(s.content)
+			return
+		}
+		s.addEscaped(markupValues, start, start+end, quote)
+		s.pos = start + end + 1
+		return
+	}
+	start := s.pos
+	for s.pos < len(s.content) && !isSpace(s.content[s.pos]) && s.content[s.pos] != '>' {
+		s.pos++
+	}
+	if s.pos > start {
+		s.addEscaped(markupValues, start, s.pos, 0)
+	}
+}
+
+// rawText consumes the content of a script or style element up to its end tag, as one text node.
+func (s *markupScanner) rawText(tag string) {
+	start := s.pos
+	end := len(s.content)
+	for i := start; ; i++ {
+		j := strings.Index(s.content[i:], "</")
+		if j < 0 {
+			break
+		}
+		i += j
+		if close := s.content[i+2:]; len(close) >= len(tag) && strings.EqualFold(close[:len(tag)], tag) {
+			end = i
+			break
+		}
+	}
+	if end > start {
+		s.nodes = append(s.nodes, markupNode{part: markupText, dataNode: dataNode{
+			span:  span{start, end},
+			value: s.content[start:end],
+			quote: func(replaced string) string { return replaced },
+		}})
+	}
+	s.pos = end
+}
+
+// addName consumes an element or attribute name at pos and adds it as a node, returning it.
+func (s *markupScanner) addName(part string) string {
+	start := s.pos
+	for s.pos < len(s.content) && isNameByte(s.content[s.pos]) {
+		s.pos++
+	}
+	if s.pos == start {
+		return ""
+	}
+	name := s.content[start:s.pos]
+	s.nodes = append(s.nodes, markupNode{part: part, dataNode: dataNode{
+		span:  span{start, s.pos},
+		value: name,
+		quote: func(replaced string) string { return replaced },
+	}})
+	return name
+}
+
+// addText adds the text between two tags as a node, unless it's only whitespace and that isn't kept.
+func (s *markupScanner) addText(start, end int) {
+	if start < end && (s.keepSpace || strings.TrimSpace(s.content[start:end]) != "") {
+		s.addEscaped(markupText, start, end, 0)
+	}
+}
+
+// addCDATA adds the content of a CDATA section as a text node. Nothing is escaped in it, but a
+// replacement containing "]]>" splits the section in two so it doesn't end early.
+func (s *markupScanner) addCDATA(start, end int) {
+	s.nodes = append(s.nodes, markupNode{part: markupText, dataNode: dataNode{
+		span:  span{start, end},
+		value: s.content[start:end],
+		quote: func(replaced string) string {
+			return strings.ReplaceAll(replaced, "]]>", "]]]]><![CDATA[>")
+		},
+	}})
+}
+
+// addEscaped adds text or an attribute value the rules see with its entity references decoded.
+// quote is the attribute value's quote character, or 0 for text and unquoted values.
+func (s *markupScanner) addEscaped(part string, start, end int, quote byte) {
+	raw := s.content[start:end]
+	decoded, offsets, spellings := decodeEntities(raw)
+	s.nodes = append(s.nodes, markupNode{part: part, dataNode: dataNode{
+		span:  span{start, end},
+		value: decoded,
+		quote: func(replaced string) string {
+			if part == markupValues && quote == 0 && strings.ContainsAny(replaced, " \t\r\n\"'=<>`") {
+				return `"` + escapeMarkup(replaced, '"', spellings) + `"` // It can't stay unquoted.
+			}
+			return reencodeEntities(raw, decoded, offsets, spellings, replaced, quote)
+		},
+	}})
+}
+
+// decodeEntities resolves the character and entity references of raw. offsets maps every byte offset
+// of the decoded text that a reference doesn't split to the matching offset in raw; the others are -1.
+// spellings maps the characters raw spells as references to the first reference used for each.
+func decodeEntities(raw string) (decoded string, offsets []int, spellings map[string]string) {
+	var b strings.Builder
+	offsets = make([]int, 0, len(raw)+1)
+	spellings = make(map[string]string)
+	last := 0
+	literal := func(end int) {
+		for i := last; i < end; i++ {
+			offsets = append(offsets, i)
+		}
+		b.WriteString(raw[last:end])
+	}
+	for _, m := range markupEntity.FindAllStringIndex(raw, -1) {
+		decoded := html.UnescapeString(raw[m[0]:m[1]])
+		if decoded == raw[m[0]:m[1]] {
+			continue // An entity HTML doesn't define, e.g. one from an XML DTD, stays as written.
+		}
+		if _, ok := spellings[decoded]; !ok {
+			spellings[decoded] = raw[m[0]:m[1]]
+		}
+		literal(m[0])
+		offsets = append(offsets, m[0])
+		for i := 1; i < len(decoded); i++ {
+			offsets = append(offsets, -1)
+		}
+		b.WriteString(decoded)
+		last = m[1]
+	}
+	literal(len(raw))
+	offsets = append(offsets, len(raw))
+	return b.String(), offsets, spellings
+}
+
+// reencodeEntities spells replaced back as raw markup. The unchanged text at either end keeps its
+// original spelling, entity references included; what changed in between is escaped afresh, reusing
+// the references the node already spelled its characters with.
+func reencodeEntities(raw, decoded string, offsets []int, spellings map[string]string, replaced string, quote byte) string {
+	prefix := 0
+	for prefix < len(decoded) && prefix < len(replaced) && decoded[prefix] == replaced[prefix] {
+		prefix++
+	}
+	for offsets[prefix] < 0 {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(decoded)-prefix && suffix < len(replaced)-prefix &&
+		decoded[len(decoded)-1-suffix] == replaced[len(replaced)-1-suffix] {
+		suffix++
+	}
+	for offsets[len(decoded)-suffix] < 0 {
+		suffix--
+	}
+	return raw[:offsets[prefix]] +
+		escapeMarkup(replaced[prefix:len(replaced)-suffix], quote, spellings) +
+		raw[offsets[len(decoded)-suffix]:]
+}
+
+// escapeMarkup escapes the characters that can't appear literally in text or in an attribute value
+// quoted with quote, and spells the characters in spellings the way it says.
+func escapeMarkup(text string, quote byte, spellings map[string]string) string {
+	escapes := map[string]string{"&": "&amp;", "<": "&lt;", ">": "&gt;"}
+	switch quote {
+	case '"':
+		escapes[`"`] = "&quot;"
+	case '\'':
+		escapes["'"] = "&#39;"
+	}
+	for char, spelling := range spellings {
+		escapes[char] = spelling
+	}
+
+	var b strings.Builder
+	for _, r := range text {
+		if escaped, ok := escapes[string(r)]; ok {
+			b.WriteString(escaped)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (s *markupScanner) skipSpace() {
+	for s.pos < len(s.content) && isSpace(s.content[s.pos]) {
+		s.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= 0x80
+}
+
+// isNameByte reports whether c can be part of an element or attribute name, namespace prefixes included.
+func isNameByte(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarkupNodes(t *testing.T) {
+	doc := `<?xml version="1.0"?><!-- item --><item id="a&amp;b" flag data-x=plain>Tom &amp; Jerry<br/></item>`
+
+	var got []string
+	for _, node := range markupNodes(doc) {
+		got = append(got, node.part+":"+node.value)
+	}
+	want := []string{
+		"elements:item", "attributes:id", "values:a&b", "attributes:flag", "attributes:data-x", "values:plain",
+		"text:Tom & Jerry", "elements:br", "elements:item",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nodes:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestMarkupParts(t *testing.T) {
+	const doc = `<order order="order"><!-- order -->order</order>`
+
+	for markup, want := range map[string]string{
+		markupElements:     `<sale order="order"><!-- order -->order</sale>`,
+		markupAttributes:   `<order sale="order"><!-- order -->order</order>`,
+		markupValues:       `<order order="sale"><!-- order -->order</order>`,
+		markupText:         `<order order="order"><!-- order -->sale</order>`,
+		"elements, values": `<sale order="sale"><!-- order -->order</sale>`,
+	} {
+		ns := NewNameShifter(&Config{CaseMatching: true, Markup: markup}, NewAppContext())
+		if got := shiftFile(t, ns, "order.xml", doc, ns.newRule("order", "sale")); got != want {
+			t.Errorf("-markup=%s:\n got %s\nwant %s", markup, got, want)
+		}
+	}
+}
+
+func TestMarkupKeepsEntitySpellings(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markup: markupText}, NewAppContext())
+
+	// &#38; spells the ampersand already, so the new one is spelled the same; &nbsp; outside the change stays.
+	got := shiftFile(t, ns, "page.html", "<p>Fish&nbsp;&#38; chips</p>", ns.newRule("chips", "fries & peas"))
+	if want := "<p>Fish&nbsp;&#38; fries &#38; peas</p>"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarkupQuotesValuesThatNeedIt(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markup: markupValues}, NewAppContext())
+
+	got := shiftFile(t, ns, "page.html", `<a title=old class='old'>`, ns.newRule("old", `it's "new"`))
+	if want := `<a title="it's &quot;new&quot;" class='it&#39;s "new"'>`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarkupRawTextAndCDATA(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markup: markupText}, NewAppContext())
+
+	// Script content isn't markup, so "a < b" needs no escaping; in CDATA, "]]>" has to be split up.
+	got := shiftFile(t, ns, "page.html", "<script>if (x < y) {}</script><![CDATA[x]]>", ns.newRule("x", "a]]>b"))
+	if want := "<script>if (a]]>b < y) {}</script><![CDATA[a]]]]><![CDATA[>b]]>"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarkupToleratesBrokenMarkup(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markup: markupText}, NewAppContext())
+
+	got := shiftFile(t, ns, "page.html", "<p>1 < 2 and 3 <= 4<p class=x old", ns.newRule("and", "or"))
+	if want := "<p>1 < 2 or 3 <= 4<p class=x old"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMarkupLeavesOtherFilesAlone(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Markup: markupText}, NewAppContext())
+
+	if got := shiftFile(t, ns, "notes.md", "<b>old</b>\n", ns.newRule("old", "new")); got != "<b>old</b>\n" {
+		t.Errorf("notes.md = %q", got)
+	}
+}
+
+func TestValidateMarkup(t *testing.T) {
+	if err := validateMarkup(&Config{Markup: "text,comments"}); err == nil {
+		t.Error("accepted -markup=text,comments")
+	}
+	if err := validateMarkup(&Config{Markup: markupText, Markdown: markdownProse}); err == nil {
+		t.Error("accepted -markup combined with -markdown")
+	}
+	if err := validateMarkup(&Config{Markup: " elements , text "}); err != nil {
+		t.Error(err)
+	}
+}
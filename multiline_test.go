@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+const licensed = "/*\n * Copyright Acme Corp.\n */\npackage store\n"
+
+func TestMultilinePatternSpansLines(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, Multiline: true}, NewAppContext())
+
+	got := shiftFile(t, ns, "store.go", licensed, ns.newRule(`(?s)/\*.*?Acme.*?\*/\n`, "// SPDX-License-Identifier: MIT\n"))
+	if want := "// SPDX-License-Identifier: MIT\npackage store\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLineModeCannotSpanLines(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true}, NewAppContext())
+
+	got := shiftFile(t, ns, "store.go", licensed, ns.newRule(`(?s)/\*.*?Acme.*?\*/\n`, ""))
+	if got != licensed {
+		t.Errorf("a line by line run matched across lines: %q", got)
+	}
+}
+
+func TestMultilineKeepsBytesOutsideMatches(t *testing.T) {
+	// No newline is added at the end, and carriage returns stay where they are.
+	ns := NewNameShifter(&Config{CaseMatching: true, Multiline: true}, NewAppContext())
+
+	got := shiftFile(t, ns, "notes.md", "old\r\nold", ns.newRule("old", "new"))
+	if got != "new\r\nnew" {
+		t.Errorf("got %q", got)
+	}
+	if ns.Context.replacementsCount != 2 {
+		t.Errorf("replacements = %d, want 2", ns.Context.replacementsCount)
+	}
+}
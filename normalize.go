@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode normalization forms -normalize accepts.
+var normalizationForms = map[string]norm.Form{
+	"nfc":  norm.NFC,
+	"nfd":  norm.NFD,
+	"nfkc": norm.NFKC,
+	"nfkd": norm.NFKD,
+}
+
+// validateNormalize checks the -normalize flag before anything is replaced.
+func validateNormalize(cfg *Config) error {
+	cfg.Normalize = strings.ToLower(cfg.Normalize)
+	if _, ok := normalizationForms[cfg.Normalize]; cfg.Normalize != "" && !ok {
+		return fmt.Errorf("invalid -normalize %q, expected \"nfc\", \"nfd\", \"nfkc\" or \"nfkd\"", cfg.Normalize)
+	}
+	return nil
+}
+
+// canonicalText is a text brought into the form rules are matched in, normalized and case folded as
+// asked, together with the way back: units maps every byte of it to the bytes of the original text it
+// came from. A unit is the smallest stretch of the original that's transformed on its own, a character
+// with its combining marks for normalization, a single character for folding.
+type canonicalText struct {
+	text  string
+	units []span
+}
+
+// canonicalize transforms text the way -normalize and, if fold is set, -fold ask for.
+func (ns *NameShifter) canonicalize(text string, fold bool) canonicalText {
+	form, normalize := normalizationForms[ns.Config.Normalize]
+	folder := cases.Fold()
+
+	var b strings.Builder
+	units := make([]span, 0, len(text))
+	for i := 0; i < len(text); {
+		_, n := utf8.DecodeRuneInString(text[i:])
+		if normalize {
+			n = max(form.NextBoundaryInString(text[i:], true), n)
+		}
+		unit := text[i : i+n]
+		if normalize {
+			unit = form.String(unit)
+		}
+		if fold {
+			unit = folder.String(unit)
+		}
+		b.WriteString(unit)
+		for range len(unit) {
+			units = append(units, span{i, i + n})
+		}
+		i += n
+	}
+	return canonicalText{b.String(), units}
+}
+
+// atUnitBoundary reports whether offset lies between two units of the canonical text, rather than inside
+// the transformation of one, such as between the two s's "ß" folds to.
+func (c canonicalText) atUnitBoundary(offset int) bool {
+	return offset == 0 || offset == len(c.text) || c.units[offset-1] != c.units[offset]
+}
+
+// originalStart maps the start of a match in the canonical text back to the original text.
+func (c canonicalText) originalStart(offset int, original string) int {
+	if offset == len(c.text) {
+		return len(original)
+	}
+	return c.units[offset].start
+}
+
+// originalEnd maps the end of a match in the canonical text back to the original text.
+func (c canonicalText) originalEnd(offset int) int {
+	if offset == 0 {
+		return 0
+	}
+	return c.units[offset-1].end
+}
+
+// findCanonicalMatches finds the matches of the rule the way findMatches does, but compares text and search
+// string in their canonical form, so "é" matches both precomposed and as "e" plus a combining accent, and
+// with -fold "STRASSE" matches "straße". The matches are mapped back to the original text, which keeps
+// every byte a replacement doesn't touch. Regex rules are normalized but not folded, case folding a
+// pattern would change its meaning, e.g. \S into \s; they can use (?i) or -cm=false instead.
+func (ns *NameShifter) findCanonicalMatches(text string, rule *Rule) [][]int {
+	fold := ns.Config.Fold && !rule.Regex
+	canonical := ns.canonicalize(text, fold)
+	canonicalRule := *rule
+	canonicalRule.Search = ns.canonicalize(rule.Search, fold).text
+	canonicalRule.Boundary = boundaryNone // Checked against the original text below.
+	if fold {
+		canonicalRule.CaseMatching = true // Folded text is compared as it is.
+	}
+
+	var matches [][]int
+	for _, match := range ns.findRawMatches(canonical.text, &canonicalRule) {
+		if !canonical.atUnitBoundary(match[0]) || !canonical.atUnitBoundary(match[1]) {
+			continue // Part of a character's transformation, e.g. one of the s's "ß" folds to.
+		}
+		mapped := make([]int, len(match))
+		for i := 0; i < len(match); i += 2 {
+			if match[i] < 0 {
+				mapped[i], mapped[i+1] = -1, -1 // A group that didn't take part in the match.
+				continue
+			}
+			mapped[i], mapped[i+1] = canonical.originalStart(match[i], text), canonical.originalEnd(match[i+1])
+		}
+		if atBoundaries(text, mapped[0], mapped[1], rule.Boundary) {
+			matches = append(matches, mapped)
+		}
+	}
+	return matches
+}
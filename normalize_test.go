@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+const (
+	cafePrecomposed = "caf\u00e9"  // é as one code point.
+	cafeDecomposed  = "cafe\u0301" // e followed by a combining acute accent.
+)
+
+func TestNormalizeMatchesEitherComposition(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Normalize: "nfc"}, NewAppContext())
+
+	text := cafePrecomposed + " " + cafeDecomposed + " cafe"
+	if got, want := replaceAll(ns, text, cafeDecomposed, "bar"), "bar bar cafe"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeKeepsUnmatchedBytes(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Normalize: "nfc"}, NewAppContext())
+
+	// Only the match is replaced; the decomposed é elsewhere isn't recomposed along the way.
+	text := "e\u0301te\u0301 " + cafePrecomposed
+	if got, want := replaceAll(ns, text, cafeDecomposed, "bar"), "e\u0301te\u0301 bar"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeCompatibilityForms(t *testing.T) {
+	// The "ﬁ" ligature and full width digits are compatibility characters.
+	text := "ﬁle １２"
+
+	canonical := NewNameShifter(&Config{CaseMatching: true, Normalize: "nfc"}, NewAppContext())
+	if got := replaceAll(canonical, text, "file", "doc"); got != text {
+		t.Errorf("nfc matched a ligature: %q", got)
+	}
+	compatible := NewNameShifter(&Config{CaseMatching: true, Normalize: "nfkc"}, NewAppContext())
+	if got := replaceAll(compatible, replaceAll(compatible, text, "file", "doc"), "12", "twelve"); got != "doc twelve" {
+		t.Errorf("nfkc: got %q", got)
+	}
+}
+
+func TestFoldMatchesFullCaseFolding(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Fold: true}, NewAppContext())
+
+	got := replaceAll(ns, "Straße STRASSE strasse Strase", "strasse", "road")
+	if want := "road road road Strase"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFoldDoesntSplitCharacters(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Fold: true}, NewAppContext())
+
+	// "ß" folds to "ss", but a single s of it isn't there to match.
+	if got := replaceAll(ns, "maß", "as", "X"); got != "maß" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFoldPreservesCase(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Fold: true, PreserveCase: true}, NewAppContext())
+
+	if got, want := replaceAll(ns, "STRASSE Straße", "straße", "road"), "ROAD Road"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeRegexGroupsMapBack(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, Normalize: "nfc", Fold: true}, NewAppContext())
+
+	// Regexes are normalized but not folded, and their groups point into the original text.
+	got := replaceAll(ns, cafeDecomposed+"-1 CAFÉ-2", "café-(\\d)", "shop$1")
+	if want := "shop1 CAFÉ-2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeHonoursBoundaries(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Normalize: "nfd", WholeWord: true}, NewAppContext())
+
+	if got, want := replaceAll(ns, cafePrecomposed+" "+cafePrecomposed+"s", cafeDecomposed, "bar"), "bar "+cafePrecomposed+"s"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateNormalize(t *testing.T) {
+	cfg := &Config{Normalize: "NFKD"}
+	if err := validateNormalize(cfg); err != nil || cfg.Normalize != "nfkd" {
+		t.Errorf("NFKD: %v, normalized to %q", err, cfg.Normalize)
+	}
+	if err := validateNormalize(&Config{Normalize: "nfx"}); err == nil {
+		t.Error("accepted -normalize=nfx")
+	}
+}
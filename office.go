@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// officeFormat describes where the text of an Office Open XML or OpenDocument file lives.
+type officeFormat struct {
+	parts      []string        // Patterns of the XML parts holding text, matched with path.Match.
+	paragraphs map[string]bool // Elements grouping runs into text a match may span.
+	texts      map[string]bool // Elements holding run text, or nil if all text in a paragraph counts.
+	breaks     map[string]bool // Empty elements standing for tabs, spaces or line breaks, which no match spans.
+	// Whether run text loses leading and trailing whitespace unless its element has xml:space="preserve".
+	trimsSpace bool
+}
+
+// Elements are named without their namespace prefix, which documents are free to choose.
+var (
+	wordprocessingML = &officeFormat{
+		parts:      []string{"word/*.xml"},
+		paragraphs: map[string]bool{"p": true},
+		texts:      map[string]bool{"t": true},
+		breaks:     map[string]bool{"tab": true, "br": true, "cr": true, "noBreakHyphen": true, "softHyphen": true},
+		trimsSpace: true,
+	}
+	spreadsheetML = &officeFormat{
+		parts:      []string{"xl/sharedStrings.xml", "xl/worksheets/*.xml", "xl/comments*.xml"},
+		paragraphs: map[string]bool{"si": true, "is": true, "text": true},
+		texts:      map[string]bool{"t": true},
+		trimsSpace: true,
+	}
+	presentationML = &officeFormat{
+		parts:      []string{"ppt/slides/*.xml", "ppt/notesSlides/*.xml"},
+		paragraphs: map[string]bool{"p": true},
+		texts:      map[string]bool{"t": true},
+		breaks:     map[string]bool{"br": true},
+	}
+	openDocument = &officeFormat{
+		parts:      []string{"content.xml", "styles.xml"},
+		paragraphs: map[string]bool{"p": true, "h": true},
+		breaks:     map[string]bool{"tab": true, "s": true, "line-break": true, "soft-page-break": true},
+	}
+)
+
+// officeFormatOf returns the format of an Office document, or nil if path isn't one.
+func officeFormatOf(path string) *officeFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".docx", ".docm", ".dotx":
+		return wordprocessingML
+	case ".xlsx", ".xlsm", ".xltx":
+		return spreadsheetML
+	case ".pptx", ".pptm", ".potx":
+		return presentationML
+	case ".odt", ".ods", ".odp", ".ott":
+		return openDocument
+	}
+	return nil
+}
+
+// processOfficeFile applies the rules to the text of an Office document. The XML parts holding text are
+// rewritten in place and every other entry of the zip container is copied over untouched, in order, so
+// e.g. an OpenDocument's uncompressed mimetype entry stays first.
+func (ns *NameShifter) processOfficeFile(path string, format *officeFormat, rules []*Rule) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	state := newFileState(path)
+	rewritten := make(map[string][]byte)
+	for _, file := range archive.File {
+		if !format.holdsText(file.Name) {
+			continue
+		}
+		content, err := readZipEntry(file)
+		if err != nil {
+			return err
+		}
+		if replaced := ns.rewriteOfficePart(string(content), format, rules, state); replaced != string(content) {
+			rewritten[file.Name] = []byte(replaced)
+		}
+	}
+	if len(rewritten) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	writer := zip.NewWriter(&b)
+	for _, file := range archive.File {
+		content, ok := rewritten[file.Name]
+		if !ok {
+			if err := writer.Copy(file); err != nil {
+				return err
+			}
+			continue
+		}
+		header := file.FileHeader
+		entry, err := writer.CreateHeader(&header)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := ns.writeFileContent(path, b.Bytes()); err != nil {
+		return err
+	}
+
+	ns.recordTally(state)
+	return nil
+}
+
+// holdsText reports whether the zip entry name is one of the format's text parts.
+func (format *officeFormat) holdsText(name string) bool {
+	for _, pattern := range format.parts {
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipEntry(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// officeRun is the text of one run, and the element holding it.
+type officeRun struct {
+	text     markupNode
+	element  markupNode // Start tag name of the text element, if the format has them.
+	preserve bool       // Whether the text element already preserves whitespace.
+}
+
+// rewriteOfficePart applies the rules to the paragraphs of an XML part. A paragraph's runs are matched
+// as one text, so a name whose letters are formatted differently still matches; the replacement goes to
+// the run where the match starts, and the rest of the match is removed from the runs it spans.
+func (ns *NameShifter) rewriteOfficePart(content string, format *officeFormat, rules []*Rule, state *fileState) string {
+	var edits []textEdit
+	preserved := make(map[int]bool)
+	for i, paragraph := range format.paragraphRuns(content) {
+		state.line = i + 1 // Paragraphs stand in for lines, e.g. in templates.
+		runs := make([]string, len(paragraph))
+		for j, run := range paragraph {
+			runs[j] = run.text.value
+		}
+		edited := ns.applyRulesToRuns(runs, rules, state)
+
+		for j, run := range paragraph {
+			if edited[j] == runs[j] {
+				continue
+			}
+			at := run.element.span.end
+			if format.trimsSpace && !run.preserve && !preserved[at] && strings.TrimSpace(edited[j]) != edited[j] {
+				edits = append(edits, textEdit{at, at, ` xml:space="preserve"`})
+				preserved[at] = true
+			}
+			edits = append(edits, textEdit{run.text.span.start, run.text.span.end, run.text.quote(edited[j])})
+		}
+	}
+	return applyEdits(content, edits)
+}
+
+// paragraphRuns groups the runs of an XML part by paragraph. Breaks split paragraphs too, since a match
+// spanning a tab or line break would otherwise glue the text on either side of it together.
+func (format *officeFormat) paragraphRuns(content string) [][]officeRun {
+	var paragraphs [][]officeRun
+	var current []officeRun
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, current)
+			current = nil
+		}
+	}
+
+	depth, inText := 0, 0
+	var element markupNode
+	preserve := false
+	for _, node := range scanMarkup(&markupScanner{content: content, keepSpace: true}) {
+		switch node.part {
+		case markupElements:
+			name := node.value[strings.IndexByte(node.value, ':')+1:]
+			switch {
+			case format.paragraphs[name] && !node.selfClosing:
+				flush()
+				if node.closing {
+					depth--
+				} else {
+					depth++
+				}
+			case format.breaks[name]:
+				flush()
+			case format.texts[name] && !node.selfClosing:
+				if node.closing {
+					inText--
+				} else {
+					inText++
+					element, preserve = node, false
+				}
+			}
+		case markupAttributes:
+			if node.value == "xml:space" {
+				preserve = true
+			}
+		case markupText:
+			if depth > 0 && (format.texts == nil || inText > 0) {
+				current = append(current, officeRun{text: node, element: element, preserve: preserve})
+			}
+		}
+	}
+	flush()
+	return paragraphs
+}
+
+// editRuns applies edits made to the concatenated text of runs to the runs themselves. Each edit's
+// replacement goes to the run its match starts in; the text it replaces is cut from every run it spans.
+func editRuns(runs []string, edits []textEdit) []string {
+	if len(edits) == 0 {
+		return runs
+	}
+	starts := make([]int, len(runs)+1)
+	for i, run := range runs {
+		starts[i+1] = starts[i] + len(run)
+	}
+	text := strings.Join(runs, "")
+
+	out := make([]strings.Builder, len(runs))
+	keep := func(from, to int) {
+		for i := range runs {
+			if start, end := max(from, starts[i]), min(to, starts[i+1]); start < end {
+				out[i].WriteString(text[start:end])
+			}
+		}
+	}
+	last := 0
+	for _, edit := range edits {
+		keep(last, edit.start)
+		owner := sort.Search(len(runs), func(i int) bool { return edit.start < starts[i+1] })
+		if owner == len(runs) {
+			owner = len(runs) - 1 // The match is empty and sits at the very end.
+		}
+		out[owner].WriteString(edit.replacement)
+		last = edit.end
+	}
+	keep(last, len(text))
+
+	edited := make([]string, len(runs))
+	for i := range out {
+		edited[i] = out[i].String()
+	}
+	return edited
+}
+
+// applyRulesToRuns runs the rules over the runs of a paragraph, the way applyRules runs them over text.
+func (ns *NameShifter) applyRulesToRuns(runs []string, rules []*Rule, state *fileState) []string {
+	if ns.prefilter != nil && !ns.prefilter.MatchString(strings.Join(runs, "")) {
+		return runs
+	}
+	if ns.Config.Simultaneous {
+		return editRuns(runs, ns.simultaneousEdits(strings.Join(runs, ""), rules, state))
+	}
+	for _, rule := range rules {
+		runs = editRuns(runs, ns.ruleEdits(strings.Join(runs, ""), rule, state))
+	}
+	return runs
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// zipEntry is a file inside an Office document.
+type zipEntry struct {
+	name, content string
+	stored        bool // Whether it's stored uncompressed, like an OpenDocument mimetype.
+}
+
+func writeZip(t *testing.T, path string, entries ...zipEntry) {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		if entry.stored {
+			header.Method = zip.Store
+		}
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readZip(t *testing.T, path string) []zipEntry {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var entries []zipEntry
+	for _, f := range r.File {
+		content, err := readZipEntry(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, zipEntry{f.Name, string(content), f.Method == zip.Store})
+	}
+	return entries
+}
+
+const wordDocument = `<w:document xmlns:w="x"><w:body>` +
+	`<w:p><w:r><w:t>Acme</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>Corp</w:t></w:r><w:r><w:t> ships</w:t></w:r></w:p>` +
+	`<w:p><w:r><w:t>Acme</w:t><w:tab/><w:t>Corp</w:t></w:r></w:p>` +
+	`</w:body></w:document>`
+
+func TestOfficeMatchesAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "letter.docx")
+	writeZip(t, path,
+		zipEntry{name: "[Content_Types].xml", content: "<Types>AcmeCorp</Types>"},
+		zipEntry{name: "word/document.xml", content: wordDocument},
+	)
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	if err := ns.processFile(path, []*Rule{ns.newRule("AcmeCorp", "Globex")}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readZip(t, path)
+	if entries[0].content != "<Types>AcmeCorp</Types>" {
+		t.Errorf("a part without text was changed: %s", entries[0].content)
+	}
+	// The replacement goes to the first run, the bold one loses its share; across the tab nothing matches.
+	want := `<w:document xmlns:w="x"><w:body>` +
+		`<w:p><w:r><w:t>Globex</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t></w:t></w:r><w:r><w:t> ships</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Acme</w:t><w:tab/><w:t>Corp</w:t></w:r></w:p>` +
+		`</w:body></w:document>`
+	if entries[1].content != want {
+		t.Errorf("document.xml:\n got %s\nwant %s", entries[1].content, want)
+	}
+	if ns.Context.replacementsCount != 1 {
+		t.Errorf("replacements = %d, want 1", ns.Context.replacementsCount)
+	}
+}
+
+func TestOfficePreservesSpaceItIntroduces(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	part := `<w:p><w:r><w:t>Acme</w:t></w:r><w:r><w:t xml:space="preserve">Acme </w:t></w:r></w:p>`
+
+	got := ns.rewriteOfficePart(part, wordprocessingML, []*Rule{ns.newRule("Acme", "Acme ")}, newFileState(""))
+	if want := `<w:p><w:r><w:t xml:space="preserve">Acme </w:t></w:r><w:r><w:t xml:space="preserve">Acme  </w:t></w:r></w:p>`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestOfficeOpenDocumentKeepsMimetypeFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.odt")
+	writeZip(t, path,
+		zipEntry{"mimetype", "application/vnd.oasis.opendocument.text", true},
+		zipEntry{name: "content.xml", content: `<office:text><text:p>Fish &amp; <text:span>chips</text:span></text:p></office:text>`},
+		zipEntry{name: "meta.xml", content: "<meta>chips</meta>"},
+	)
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	if err := ns.processFile(path, []*Rule{ns.newRule("chips", "fries & peas")}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []zipEntry{
+		{"mimetype", "application/vnd.oasis.opendocument.text", true},
+		{"content.xml", `<office:text><text:p>Fish &amp; <text:span>fries &amp; peas</text:span></text:p></office:text>`, false},
+		{"meta.xml", "<meta>chips</meta>", false},
+	}
+	if got := readZip(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestOfficeSpreadsheetSharedStrings(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+	part := `<sst><si><t>old</t></si><si><r><t>o</t></r><r><t>ld</t></r></si><si><t>gold</t></si></sst>`
+
+	got := ns.rewriteOfficePart(part, spreadsheetML, []*Rule{ns.newRule("old", "new")}, newFileState(""))
+	if want := `<sst><si><t>new</t></si><si><r><t>new</t></r><r><t></t></r></si><si><t>gnew</t></si></sst>`; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestOfficeFileWithoutMatchesIsUntouched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.pptx")
+	writeZip(t, path, zipEntry{name: "ppt/slides/slide1.xml", content: "<p:sld><a:p><a:r><a:t>Hello</a:t></a:r></a:p></p:sld>"})
+	before, _ := os.ReadFile(path)
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	if err := ns.processFile(path, []*Rule{ns.newRule("Goodbye", "Hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+		t.Error("deck.pptx was rewritten without a match")
+	}
+}
+
+func TestEditRuns(t *testing.T) {
+	tests := []struct {
+		runs  []string
+		edits []textEdit
+		want  []string
+	}{
+		{[]string{"ab", "cd"}, []textEdit{{1, 3, "X"}}, []string{"aX", "d"}},
+		{[]string{"ab", "cd", "ef"}, []textEdit{{1, 5, ""}}, []string{"a", "", "f"}},
+		{[]string{"ab", "cd"}, []textEdit{{2, 2, "X"}}, []string{"ab", "Xcd"}},
+		{[]string{"ab", "cd"}, []textEdit{{4, 4, "X"}}, []string{"ab", "cdX"}},
+		{[]string{"ab", "cd"}, []textEdit{{0, 1, "X"}, {3, 4, "Y"}}, []string{"Xb", "cY"}},
+	}
+	for _, tt := range tests {
+		if got := editRuns(tt.runs, tt.edits); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("editRuns(%q, %v) = %q, want %q", tt.runs, tt.edits, got, tt.want)
+		}
+	}
+}
+
+func TestOfficeFormatOf(t *testing.T) {
+	for name, want := range map[string]*officeFormat{
+		"a.DOCX": wordprocessingML, "a.xlsm": spreadsheetML, "a.potx": presentationML, "a.ods": openDocument, "a.doc": nil,
+	} {
+		if got := officeFormatOf(name); got != want {
+			t.Errorf("officeFormatOf(%s) is wrong", name)
+		}
+	}
+	if !wordprocessingML.holdsText("word/footer2.xml") || wordprocessingML.holdsText("word/media/x.xml") {
+		t.Error("holdsText doesn't match word/*.xml")
+	}
+}
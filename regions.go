@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// span is the half-open byte range [start, end) of a file's content.
+type span struct {
+	start, end int
+}
+
+// Scopes a file's content can be narrowed down to with -scope.
+const (
+	scopeAll      = ""
+	scopeComments = "comments"
+	scopeStrings  = "strings"
+	scopeCode     = "code"
+)
+
+// validateScope checks the -scope flag before anything is replaced.
+func validateScope(scope string) error {
+	switch scope {
+	case scopeAll, scopeComments, scopeStrings, scopeCode:
+		return nil
+	}
+	return fmt.Errorf("invalid -scope %q, expected \"comments\", \"strings\" or \"code\"", scope)
+}
+
+// canScope reports whether the content of path can be narrowed down to the configured scope:
+// Go files are classified with go/scanner, other languages with a lexer profile picked by extension.
+func (ns *NameShifter) canScope(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".go") || profileFor(path) != nil
+}
+
+// scopeSpans returns the parts of content belonging to the configured scope, or the whole content when no scope is set.
+func (ns *NameShifter) scopeSpans(path, content string) ([]span, error) {
+	if ns.Config.Markdown != markdownAll {
+		return ns.markdownSpans(content), nil
+	}
+	if ns.Config.Scope == scopeAll {
+		return []span{{0, len(content)}}, nil
+	}
+	var comments, literals []span
+	if profile := profileFor(path); profile != nil {
+		comments, literals = profile.regions(content)
+	} else {
+		var err error
+		if comments, literals, err = goRegions(content); err != nil {
+			return nil, err
+		}
+	}
+	switch ns.Config.Scope {
+	case scopeComments:
+		return comments, nil
+	case scopeStrings:
+		return literals, nil
+	}
+	return complementSpans(mergeSpans(comments, literals), len(content)), nil
+}
+
+// addressSpans returns the lines the line address allows, merged into blocks of consecutive lines.
+func (ns *NameShifter) addressSpans(content string, state *fileState) []span {
+	if ns.address == nil {
+		return []span{{0, len(content)}}
+	}
+	var spans []span
+	offset := 0
+	for index, line := range strings.SplitAfter(content, "\n") {
+		state.line = index + 1
+		text, _ := splitLineEnding(line)
+		if ns.address.allows(text, state) {
+			spans = append(spans, span{offset, offset + len(line)})
+		}
+		offset += len(line)
+	}
+	return mergeSpans(spans, nil)
+}
+
+// splitSpansAtLines cuts spans at line breaks, leaving the breaks themselves out, so matches can't cross lines.
+func splitSpansAtLines(content string, spans []span) []span {
+	var lines []span
+	for _, s := range spans {
+		start := s.start
+		for start < s.end {
+			end := strings.IndexByte(content[start:s.end], '\n')
+			if end < 0 {
+				lines = append(lines, span{start, s.end})
+				break
+			}
+			lineEnd := start + end
+			if lineEnd > start && content[lineEnd-1] == '\r' {
+				lines = append(lines, span{start, lineEnd - 1})
+			} else {
+				lines = append(lines, span{start, lineEnd})
+			}
+			start = lineEnd + 1
+		}
+	}
+	return lines
+}
+
+// mergeSpans combines two sorted lists of spans into one sorted list, joining spans that touch or overlap.
+func mergeSpans(a, b []span) []span {
+	all := make([]span, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		var next span
+		if len(b) == 0 || (len(a) > 0 && a[0].start <= b[0].start) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+		if n := len(all); n > 0 && next.start <= all[n-1].end {
+			if next.end > all[n-1].end {
+				all[n-1].end = next.end
+			}
+			continue
+		}
+		all = append(all, next)
+	}
+	return all
+}
+
+// intersectSpans returns the ranges covered by both sorted lists of spans.
+func intersectSpans(a, b []span) []span {
+	var both []span
+	for len(a) > 0 && len(b) > 0 {
+		start, end := max(a[0].start, b[0].start), min(a[0].end, b[0].end)
+		if start < end {
+			both = append(both, span{start, end})
+		}
+		if a[0].end < b[0].end {
+			a = a[1:]
+		} else {
+			b = b[1:]
+		}
+	}
+	return both
+}
+
+// complementSpans returns the gaps between sorted spans within [0, length).
+func complementSpans(spans []span, length int) []span {
+	var gaps []span
+	last := 0
+	for _, s := range spans {
+		if s.start > last {
+			gaps = append(gaps, span{last, s.start})
+		}
+		last = s.end
+	}
+	if last < length {
+		gaps = append(gaps, span{last, length})
+	}
+	return gaps
+}
+
+// rewriteSpans applies the rules to every span of content on its own, leaving everything in between untouched.
+func (ns *NameShifter) rewriteSpans(content string, spans []span, rules []*Rule, state *fileState) string {
+	var b strings.Builder
+	last, line := 0, 1
+	for _, s := range spans {
+		line += strings.Count(content[last:s.start], "\n")
+		b.WriteString(content[last:s.start])
+		state.line = line
+		b.WriteString(ns.applyRules(content[s.start:s.end], rules, state))
+		line += strings.Count(content[s.start:s.end], "\n")
+		last = s.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
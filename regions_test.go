@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpanAlgebra(t *testing.T) {
+	a := []span{{0, 4}, {10, 12}}
+	b := []span{{2, 6}, {6, 8}, {20, 25}}
+
+	if got, want := mergeSpans(a, b), []span{{0, 8}, {10, 12}, {20, 25}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSpans = %v, want %v", got, want)
+	}
+	if got, want := intersectSpans(a, b), []span{{2, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("intersectSpans = %v, want %v", got, want)
+	}
+	if got, want := complementSpans(a, 15), []span{{4, 10}, {12, 15}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("complementSpans = %v, want %v", got, want)
+	}
+	if got := complementSpans([]span{{0, 15}}, 15); len(got) != 0 {
+		t.Errorf("complement of everything = %v, want nothing", got)
+	}
+}
+
+func TestSplitSpansAtLines(t *testing.T) {
+	content := "ab\r\ncd\nef"
+	got := splitSpansAtLines(content, []span{{1, len(content)}})
+	if want := []span{{1, 2}, {4, 6}, {7, 9}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v: line breaks, \\r included, belong to no span", got, want)
+	}
+}
+
+const scopedSource = "package p\n\n// Save the foo.\nfunc foo() string { return \"foo\" }\n"
+
+func TestScopedReplacement(t *testing.T) {
+	want := map[string]string{
+		scopeComments: "package p\n\n// Save the bar.\nfunc foo() string { return \"foo\" }\n",
+		scopeStrings:  "package p\n\n// Save the foo.\nfunc foo() string { return \"bar\" }\n",
+		scopeCode:     "package p\n\n// Save the foo.\nfunc bar() string { return \"foo\" }\n",
+		scopeAll:      "package p\n\n// Save the bar.\nfunc bar() string { return \"bar\" }\n",
+	}
+	for scope, expected := range want {
+		ns := NewNameShifter(&Config{CaseMatching: true, Scope: scope}, NewAppContext())
+		if got := shiftFile(t, ns, "p.go", scopedSource, ns.newRule("foo", "bar")); got != expected {
+			t.Errorf("scope %q: got %q, want %q", scope, got, expected)
+		}
+	}
+}
+
+func TestScopedReplacementMatchesLineByLine(t *testing.T) {
+	// Without -multiline a comment spanning lines is still matched one line at a time.
+	content := "package p\n/* first\nsecond */\n"
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, Scope: scopeComments}, NewAppContext())
+
+	if got := shiftFile(t, ns, "p.go", content, ns.newRule(`first\nsecond`, "both")); got != content {
+		t.Errorf("got %q", got)
+	}
+	ns.Config.Multiline = true
+	if got := shiftFile(t, ns, "p.go", content, ns.newRule(`first\nsecond`, "both")); got != "package p\n/* both */\n" {
+		t.Errorf("with -multiline got %q", got)
+	}
+}
+
+func TestScopeLeavesOtherFilesAlone(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Scope: scopeComments}, NewAppContext())
+
+	if got := shiftFile(t, ns, "notes.txt", "// foo\n", ns.newRule("foo", "bar")); got != "// foo\n" {
+		t.Errorf("a text file was rewritten under -scope: %q", got)
+	}
+}
+
+func TestScopeReportsUnparsableGo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.go")
+	if err := os.WriteFile(path, []byte("package p\nvar s = \"foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ns := NewNameShifter(&Config{CaseMatching: true, Scope: scopeStrings}, NewAppContext())
+
+	if err := ns.processFile(path, []*Rule{ns.newRule("foo", "bar")}); err == nil {
+		t.Error("expected an error for a file whose strings can't be told apart")
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	for _, scope := range []string{scopeAll, scopeComments, scopeStrings, scopeCode} {
+		if err := validateScope(scope); err != nil {
+			t.Errorf("validateScope(%q) = %v", scope, err)
+		}
+	}
+	if err := validateScope("docs"); err == nil {
+		t.Error("validateScope accepted an unknown scope")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleSpec is a rule as written in a rules file. Options left out fall back to the command line flags.
+type ruleSpec struct {
+	Name         string   `json:"name" yaml:"name" toml:"name"`
+	Search       string   `json:"search" yaml:"search" toml:"search"`
+	Replacement  string   `json:"replace" yaml:"replace" toml:"replace"`
+	Regex        *bool    `json:"regex" yaml:"regex" toml:"regex"`
+	CaseMatching *bool    `json:"case_matching" yaml:"case_matching" toml:"case_matching"`
+	PreserveCase *bool    `json:"preserve_case" yaml:"preserve_case" toml:"preserve_case"`
+	Word         string   `json:"word" yaml:"word" toml:"word"` // "word" or "identifier".
+	Template     *bool    `json:"template" yaml:"template" toml:"template"`
+	Include      []string `json:"include" yaml:"include" toml:"include"`
+	Exclude      []string `json:"exclude" yaml:"exclude" toml:"exclude"`
+}
+
+// ruleFile is the document layout of YAML, JSON and TOML rules files.
+type ruleFile struct {
+	Rules []ruleSpec `json:"rules" yaml:"rules" toml:"rules"`
+}
+
+// loadRules reads an ordered list of rules from a YAML, JSON, TOML or tab-separated file,
+// picking the format from the file extension.
+func (ns *NameShifter) loadRules(rulesPath string) ([]*Rule, error) {
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rules file: %w", err)
+	}
+
+	var specs []ruleSpec
+	switch strings.ToLower(filepath.Ext(rulesPath)) {
+	case ".json":
+		specs, err = decodeRuleDocument(data, json.Unmarshal)
+	case ".yaml", ".yml":
+		specs, err = decodeRuleDocument(data, yaml.Unmarshal)
+	case ".toml":
+		var document ruleFile
+		err = toml.Unmarshal(data, &document)
+		specs = document.Rules
+	default:
+		specs, err = parseTSVRules(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse rules file %s: %w", rulesPath, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("rules file %s has no rules", rulesPath)
+	}
+
+	rules := make([]*Rule, 0, len(specs))
+	for i, spec := range specs {
+		rule, err := ns.ruleFromSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d in %s: %w", i+1, rulesPath, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// decodeRuleDocument accepts either a {"rules": [...]} document or a bare list of rules.
+func decodeRuleDocument(data []byte, unmarshal func([]byte, interface{}) error) ([]ruleSpec, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '-') {
+		var specs []ruleSpec
+		err := unmarshal(data, &specs)
+		return specs, err
+	}
+	var document ruleFile
+	err := unmarshal(data, &document)
+	return document.Rules, err
+}
+
+// parseTSVRules reads one rule per line as "search<TAB>replacement[<TAB>options]", where options is a
+// comma-separated list of regex, icase, case, preserve-case, template, word, identifier-word, include=GLOB and exclude=GLOB.
+// Blank lines and lines starting with '#' are skipped.
+func parseTSVRules(data []byte) ([]ruleSpec, error) {
+	var specs []ruleSpec
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected search, replacement and optional options separated by tabs", lineNumber)
+		}
+		spec := ruleSpec{Search: fields[0], Replacement: fields[1]}
+		if len(fields) == 3 {
+			if err := applyTSVOptions(&spec, fields[2]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, scanner.Err()
+}
+
+// applyTSVOptions fills in the options column of a tab-separated rule.
+func applyTSVOptions(spec *ruleSpec, options string) error {
+	enabled, disabled := true, false
+	for _, option := range strings.Split(options, ",") {
+		option = strings.TrimSpace(option)
+		name, value, _ := strings.Cut(option, "=")
+		switch name {
+		case "":
+		case "regex":
+			spec.Regex = &enabled
+		case "case":
+			spec.CaseMatching = &enabled
+		case "icase":
+			spec.CaseMatching = &disabled
+		case "preserve-case":
+			spec.CaseMatching, spec.PreserveCase = &disabled, &enabled
+		case "template":
+			spec.Template = &enabled
+		case "word":
+			spec.Word = "word"
+		case "identifier-word":
+			spec.Word = "identifier"
+		case "include":
+			spec.Include = append(spec.Include, value)
+		case "exclude":
+			spec.Exclude = append(spec.Exclude, value)
+		default:
+			return fmt.Errorf("unknown option %q", option)
+		}
+	}
+	return nil
+}
+
+// ruleFromSpec turns a rules file entry into a rule, filling unset options from the command line.
+func (ns *NameShifter) ruleFromSpec(spec ruleSpec) (*Rule, error) {
+	rule := ns.newRule(spec.Search, spec.Replacement)
+	if spec.Name != "" {
+		rule.Name = spec.Name
+	}
+	if spec.Regex != nil {
+		rule.Regex = *spec.Regex
+	}
+	if spec.CaseMatching != nil {
+		rule.CaseMatching = *spec.CaseMatching
+	}
+	if spec.PreserveCase != nil {
+		rule.PreserveCase = *spec.PreserveCase
+	}
+	if spec.Template != nil {
+		rule.Template = *spec.Template
+	}
+
+	switch spec.Word {
+	case "":
+	case "word":
+		rule.Boundary = boundaryWord
+	case "identifier":
+		rule.Boundary = boundaryIdentifier
+	default:
+		return nil, fmt.Errorf("unknown word mode %q, expected \"word\" or \"identifier\"", spec.Word)
+	}
+
+	for _, pattern := range append(append([]string{}, spec.Include...), spec.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	rule.Include, rule.Exclude = spec.Include, spec.Exclude
+	return rule, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRulesFile writes content to a rules file with the given name in a temporary directory.
+func writeRulesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRulesFormats(t *testing.T) {
+	files := map[string]string{
+		"rules.yaml": "rules:\n  - search: acme\n    replace: globex\n    case_matching: false\n  - search: 'v(\\d+)'\n    replace: 'version-$1'\n    regex: true\n",
+		"list.yml":   "- search: acme\n  replace: globex\n  case_matching: false\n- search: 'v(\\d+)'\n  replace: 'version-$1'\n  regex: true\n",
+		"rules.json": `{"rules": [{"search": "acme", "replace": "globex", "case_matching": false}, {"search": "v(\\d+)", "replace": "version-$1", "regex": true}]}`,
+		"list.json":  `[{"search": "acme", "replace": "globex", "case_matching": false}, {"search": "v(\\d+)", "replace": "version-$1", "regex": true}]`,
+		"rules.toml": "[[rules]]\nsearch = 'acme'\nreplace = 'globex'\ncase_matching = false\n\n[[rules]]\nsearch = 'v(\\d+)'\nreplace = 'version-$1'\nregex = true\n",
+		"rules.tsv":  "# acme is renamed everywhere\nacme\tglobex\ticase\n\nv(\\d+)\tversion-$1\tregex\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+			rules, err := ns.loadRules(writeRulesFile(t, name, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rules) != 2 {
+				t.Fatalf("got %d rules, want 2", len(rules))
+			}
+			if r := rules[0]; r.Search != "acme" || r.Replacement != "globex" || r.CaseMatching || r.Regex {
+				t.Errorf("first rule = %+v", *r)
+			}
+			if r := rules[1]; r.Search != `v(\d+)` || r.Replacement != "version-$1" || !r.CaseMatching || !r.Regex {
+				t.Errorf("second rule = %+v", *r)
+			}
+		})
+	}
+}
+
+func TestRuleOptionsFallBackToFlags(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: false, PreserveCase: true, WholeWord: true}, NewAppContext())
+
+	rules, err := ns.loadRules(writeRulesFile(t, "rules.yaml", "- search: id\n  replace: key\n- search: Id\n  replace: Key\n  case_matching: true\n  word: identifier\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules[0]; r.CaseMatching || !r.PreserveCase || r.Boundary != boundaryWord {
+		t.Errorf("first rule should take every option from the flags, got %+v", *r)
+	}
+	if r := rules[1]; !r.CaseMatching || r.Boundary != boundaryIdentifier {
+		t.Errorf("second rule should override case matching and word mode, got %+v", *r)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"empty.yaml", "rules: []\n", "has no rules"},
+		{"word.yaml", "- search: a\n  replace: b\n  word: sentence\n", `unknown word mode "sentence"`},
+		{"glob.json", `[{"search": "a", "replace": "b", "include": ["[docs"]}]`, "invalid path pattern"},
+		{"option.tsv", "a\tb\tloud\n", `line 1: unknown option "loud"`},
+		{"columns.tsv", "a\n", "line 1: expected search, replacement"},
+	}
+	for _, tt := range tests {
+		ns := NewNameShifter(&Config{}, NewAppContext())
+		_, err := ns.loadRules(writeRulesFile(t, tt.name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestMatchesAnyPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.md", "docs/guide.md", true},
+		{"docs", "docs/guide.md", true},
+		{"docs", "src/docs.go", false},
+		{"docs/legacy", "docs/legacy/old.md", true},
+		{"docs/legacy", "src/docs/legacy/old.md", false},
+		{"vendor", "internal/vendor/lib.go", true},
+	}
+	for _, tt := range tests {
+		if got := matchesAnyPath([]string{tt.pattern}, filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("matchesAnyPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRulesApplyInOrderInOnePass(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":        "alpha beta\n",
+		"docs/readme.md": "alpha beta\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second rule sees the output of the first, and the third skips the docs directory.
+	ns := NewNameShifter(&Config{CaseMatching: true, FileExtensions: []string{".go", ".md"}}, NewAppContext())
+	rules := []*Rule{ns.newRule("alpha", "beta"), ns.newRule("beta beta", "gamma"), ns.newRule("gamma", "delta")}
+	rules[2].Exclude = []string{"docs"}
+
+	paths, err := ns.collectPaths(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.ProcessAllPaths(paths, rules)
+
+	for name, want := range map[string]string{"main.go": "delta\n", "docs/readme.md": "gamma\n"} {
+		got, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
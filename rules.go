@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Rule is a single search/replacement pair together with the options used to match it.
+type Rule struct {
+	Name         string // Label shown in the per-rule report.
+	Search       string
+	Replacement  string
+	Regex        bool
+	CaseMatching bool
+	PreserveCase bool
+	Boundary     boundaryMode
+	Template     bool     // The replacement is a text/template, see executeTemplate.
+	Include      []string // Path globs the rule is limited to, relative to the starting directory.
+	Exclude      []string // Path globs the rule never touches.
+
+	template *template.Template // Parsed replacement template, set by validateRule.
+}
+
+// buildRules assembles the rules for a run: the <old> <new> pair from the command line, expanded into
+// identifier variants when asked to, followed by the rules from the rules file, if any.
+func (ns *NameShifter) buildRules(args []string) ([]*Rule, error) {
+	var rules []*Rule
+	if len(args) >= 3 {
+		search, err := ns.decodeArgument(args[1], true)
+		if err != nil {
+			return nil, err
+		}
+		replacement, err := ns.decodeArgument(args[2], false)
+		if err != nil {
+			return nil, err
+		}
+
+		if ns.Config.Variants {
+			variants, err := ns.identifierVariants(search, replacement)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, variants...)
+		} else {
+			rules = append(rules, ns.newRule(search, replacement))
+		}
+		if ns.Config.Swap {
+			if ns.Config.Regex {
+				return nil, fmt.Errorf("swapping needs literal strings, a regex replacement can't be searched for")
+			}
+			for _, rule := range rules {
+				rules = append(rules, rule.reversed())
+			}
+		}
+	}
+	if ns.Config.RulesFile != "" {
+		loaded, err := ns.loadRules(ns.Config.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, loaded...)
+	}
+
+	for _, rule := range rules {
+		if err := ns.validateRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// newRule creates a rule for the given pair, taking its matching options from the command line.
+func (ns *NameShifter) newRule(search, replacement string) *Rule {
+	return &Rule{
+		Name:         fmt.Sprintf("%s → %s", displayString(search), displayString(replacement)),
+		Search:       search,
+		Replacement:  replacement,
+		Regex:        ns.Config.Regex,
+		CaseMatching: ns.Config.CaseMatching,
+		PreserveCase: ns.Config.PreserveCase,
+		Boundary:     ns.boundaryMode(),
+		Template:     ns.Config.Template,
+	}
+}
+
+// boundaryMode picks the word boundary mode requested on the command line.
+func (ns *NameShifter) boundaryMode() boundaryMode {
+	switch {
+	case ns.Config.IdentWord:
+		return boundaryIdentifier
+	case ns.Config.WholeWord:
+		return boundaryWord
+	}
+	return boundaryNone
+}
+
+// reversed returns a copy of the rule that replaces the replacement with the search string.
+func (rule *Rule) reversed() *Rule {
+	reverse := *rule
+	reverse.Search, reverse.Replacement = rule.Replacement, rule.Search
+	reverse.Name = fmt.Sprintf("%s → %s", displayString(reverse.Search), displayString(reverse.Replacement))
+	return &reverse
+}
+
+// appliesTo reports whether the rule's path filters allow it to touch relPath.
+func (rule *Rule) appliesTo(relPath string) bool {
+	if len(rule.Include) > 0 && !matchesAnyPath(rule.Include, relPath) {
+		return false
+	}
+	return !matchesAnyPath(rule.Exclude, relPath)
+}
+
+// matchesAnyPath reports whether relPath matches one of the globs. Globs without a slash are matched
+// against every element of the path, so "docs" covers a docs directory and "*.md" any Markdown file;
+// the others are matched against the slash-separated path, where matching a directory also covers
+// everything below it.
+func matchesAnyPath(patterns []string, relPath string) bool {
+	slashed := filepath.ToSlash(relPath)
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			for _, element := range strings.Split(slashed, "/") {
+				if ok, _ := path.Match(pattern, element); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for prefix := slashed; prefix != "." && prefix != "/"; prefix = path.Dir(prefix) {
+			if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), prefix); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rulesFor narrows rules down to the ones whose path filters allow them to touch path.
+func (ns *NameShifter) rulesFor(entityPath string, rules []*Rule) []*Rule {
+	relPath, err := filepath.Rel(ns.root, entityPath)
+	if err != nil {
+		relPath = entityPath
+	}
+	applicable := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.appliesTo(relPath) {
+			applicable = append(applicable, rule)
+		}
+	}
+	return applicable
+}
+
+// prepareRules builds a single pattern matching anything any of the rules could match. Lines it
+// doesn't match are left alone without running every rule over them, which keeps large rule sets
+// down to one scan per line in the common case.
+func (ns *NameShifter) prepareRules(rules []*Rule) {
+	if ns.Config.Normalize != "" || ns.Config.Fold {
+		ns.prefilter = nil // The rules match canonical text, which the raw text may not look like.
+		return
+	}
+	alternatives := make([]string, len(rules))
+	for i, rule := range rules {
+		alternatives[i] = "(?:" + patternExpression(rule) + ")"
+	}
+	// A pattern RE2 can't combine simply leaves every line to the rules themselves.
+	ns.prefilter, _ = regexp.Compile(strings.Join(alternatives, "|"))
+}
+
+// validateRule makes sure a rule can be applied before any file is touched.
+func (ns *NameShifter) validateRule(rule *Rule) error {
+	if rule.Search == "" {
+		return fmt.Errorf("rule %q has an empty search string", rule.Name)
+	}
+	if rule.Template {
+		parsed, err := template.New(rule.Name).Funcs(ns.templateFuncs()).Parse(rule.Replacement)
+		if err != nil {
+			return fmt.Errorf("rule %q has an invalid replacement template: %w", rule.Name, err)
+		}
+		rule.template = parsed
+	}
+	if rule.CaseMatching && !rule.Regex {
+		return nil
+	}
+	_, err := ns.compilePattern(rule)
+	return err
+}
+
+// identifierStyle renders a list of words in one identifier naming convention.
+type identifierStyle struct {
+	name   string
+	render func(words []string) string
+}
+
+var identifierStyles = []identifierStyle{
+	{"camelCase", func(words []string) string {
+		return applyShape(words[0], shapeLower) + joinShaped(words[1:], "", shapeTitle)
+	}},
+	{"PascalCase", func(words []string) string { return joinShaped(words, "", shapeTitle) }},
+	{"snake_case", func(words []string) string { return joinShaped(words, "_", shapeLower) }},
+	{"SCREAMING_SNAKE_CASE", func(words []string) string { return joinShaped(words, "_", shapeUpper) }},
+	{"kebab-case", func(words []string) string { return joinShaped(words, "-", shapeLower) }},
+}
+
+// joinShaped recases every word to shape and joins them with separator.
+func joinShaped(words []string, separator string, shape caseShape) string {
+	shaped := make([]string, len(words))
+	for i, word := range words {
+		shaped[i] = applyShape(word, shape)
+	}
+	return strings.Join(shaped, separator)
+}
+
+// splitWords returns the words of a phrase or identifier, e.g. "order item", "orderItem" and "order_item" all yield "order" and "item".
+func splitWords(s string) []string {
+	var words []string
+	for _, span := range wordSpans(s) {
+		words = append(words, s[span[0]:span[1]])
+	}
+	return words
+}
+
+// identifierVariants expands an old/new pair of terms into one case-sensitive literal rule per
+// identifier convention, e.g. "order item" → "line item" yields orderItem → lineItem,
+// ORDER_ITEM → LINE_ITEM and so on. Conventions that spell the old term identically are merged.
+func (ns *NameShifter) identifierVariants(oldTerm, newTerm string) ([]*Rule, error) {
+	oldWords, newWords := splitWords(oldTerm), splitWords(newTerm)
+	if len(oldWords) == 0 || len(newWords) == 0 {
+		return nil, fmt.Errorf("variant expansion needs words to work with, got %q and %q", oldTerm, newTerm)
+	}
+
+	var rules []*Rule
+	styles := make(map[*Rule][]string)
+	seen := make(map[string]*Rule)
+	for _, style := range identifierStyles {
+		search := style.render(oldWords)
+		rule, ok := seen[search]
+		if !ok {
+			rule = &Rule{Search: search, Replacement: style.render(newWords), CaseMatching: true, Boundary: ns.boundaryMode()}
+			seen[search] = rule
+			rules = append(rules, rule)
+		}
+		styles[rule] = append(styles[rule], style.name)
+	}
+	for _, rule := range rules {
+		rule.Name = fmt.Sprintf("%s: %s → %s", strings.Join(styles[rule], ", "), rule.Search, rule.Replacement)
+	}
+	return rules, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentifierVariants(t *testing.T) {
+	ns := NewNameShifter(&Config{}, NewAppContext())
+
+	rules, err := ns.identifierVariants("order item", "line item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"orderItem":  "lineItem",
+		"OrderItem":  "LineItem",
+		"order_item": "line_item",
+		"ORDER_ITEM": "LINE_ITEM",
+		"order-item": "line-item",
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(want))
+	}
+	for _, rule := range rules {
+		if want[rule.Search] != rule.Replacement {
+			t.Errorf("%s → %s, want %s", rule.Search, rule.Replacement, want[rule.Search])
+		}
+		if !rule.CaseMatching || rule.Regex {
+			t.Errorf("rule %q should be a case-sensitive literal", rule.Name)
+		}
+	}
+}
+
+func TestIdentifierVariantsMergeIdenticalSpellings(t *testing.T) {
+	ns := NewNameShifter(&Config{}, NewAppContext())
+
+	// A single word is spelled the same in camelCase, snake_case and kebab-case.
+	rules, err := ns.identifierVariants("user", "account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want user, User and USER", len(rules))
+	}
+	if want := "camelCase, snake_case, kebab-case: user → account"; rules[0].Name != want {
+		t.Errorf("first rule is named %q, want %q", rules[0].Name, want)
+	}
+}
+
+func TestIdentifierVariantsNeedWords(t *testing.T) {
+	ns := NewNameShifter(&Config{}, NewAppContext())
+
+	if _, err := ns.identifierVariants("__", "line item"); err == nil {
+		t.Error("expected an error for a search term without words")
+	}
+}
+
+func TestProcessFileCountsEachVariant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order.go")
+	content := "var orderItem OrderItem // ORDER_ITEM\nconst ORDER_ITEM = \"order-item\"\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := NewNameShifter(&Config{}, NewAppContext())
+	rules, err := ns.identifierVariants("orderItem", "lineItem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.processFile(path, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := os.ReadFile(path)
+	if want := "var lineItem LineItem // LINE_ITEM\nconst LINE_ITEM = \"line-item\"\n"; string(got) != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	counts := map[string]int{}
+	for _, rule := range rules {
+		counts[rule.Search] = ns.Context.ruleCounts[rule]
+	}
+	want := map[string]int{"orderItem": 1, "OrderItem": 1, "order_item": 0, "ORDER_ITEM": 2, "order-item": 1}
+	for search, count := range want {
+		if counts[search] != count {
+			t.Errorf("%s replaced %d times, want %d", search, counts[search], count)
+		}
+	}
+	if ns.Context.replacementsCount != 5 {
+		t.Errorf("total = %d, want 5", ns.Context.replacementsCount)
+	}
+}
+
+func TestBuildRulesSwap(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Swap: true, Simultaneous: true}, NewAppContext())
+
+	rules, err := ns.buildRules([]string{"dir", "left", "right"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1].Search != "right" || rules[1].Replacement != "left" {
+		t.Fatalf("rules = %v, want left → right followed by its reverse", rules)
+	}
+	if rules[1].Name != "right → left" {
+		t.Errorf("reverse rule is named %q", rules[1].Name)
+	}
+}
+
+func TestBuildRulesSwapVariants(t *testing.T) {
+	ns := NewNameShifter(&Config{Swap: true, Simultaneous: true, Variants: true}, NewAppContext())
+
+	rules, err := ns.buildRules([]string{"dir", "buyer", "seller"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// buyer, Buyer and BUYER plus their three reverses.
+	if len(rules) != 6 {
+		t.Fatalf("got %d rules, want 6", len(rules))
+	}
+	if got := ns.applyRules("Buyer pays seller", rules, newFileState("")); got != "Seller pays buyer" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBuildRulesSwapRejectsRegex(t *testing.T) {
+	ns := NewNameShifter(&Config{Swap: true, Regex: true}, NewAppContext())
+
+	if _, err := ns.buildRules([]string{"dir", `a+`, "b"}); err == nil {
+		t.Error("expected swapping a regex to fail")
+	}
+}
+
+func TestProcessSinglePathRewritesAndRenamesMatchingFiles(t *testing.T) {
+	root := writeTree(t, map[string]string{"order_item.go": "type OrderItem struct{}\n"})
+
+	ns := NewNameShifter(&Config{CaseMatching: true, Variants: true, WorkGlobally: true, FileExtensions: []string{".go"}}, NewAppContext())
+	rules, err := ns.buildRules([]string{root, "order item", "line item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.prepareRules(rules)
+	ns.processSinglePath(filepath.Join(root, "order_item.go"), rules)
+
+	if got := readTree(t, root, "line_item.go"); got != "type LineItem struct{}\n" {
+		t.Errorf("line_item.go = %q, want its contents rewritten too", got)
+	}
+}
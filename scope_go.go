@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// goRegions classifies Go source with go/scanner and returns the spans of its comments and of the
+// contents of its string and rune literals, quotes excluded.
+func goRegions(content string) (comments, literals []span, err error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(content))
+
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(file, []byte(content), func(pos token.Position, msg string) { errs.Add(pos, msg) }, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		start := file.Offset(pos)
+		switch tok {
+		case token.COMMENT:
+			comments = append(comments, span{start, start + goTokenLength(content[start:], lit)})
+		case token.STRING, token.CHAR:
+			end := start + goTokenLength(content[start:], lit)
+			literals = append(literals, span{start + 1, end - 1})
+		}
+	}
+	if errs.Len() > 0 {
+		return nil, nil, fmt.Errorf("could not tell comments and strings apart: %w", errs.Err())
+	}
+	return comments, literals, nil
+}
+
+// goTokenLength returns the length of a token in the source. The scanner drops carriage returns from
+// comments and raw strings, so their literal text can be shorter than the source it came from.
+func goTokenLength(source, lit string) int {
+	switch {
+	case strings.HasPrefix(source, "/*"):
+		return strings.Index(source, "*/") + 2
+	case strings.HasPrefix(source, "//"):
+		end := strings.IndexByte(source, '\n')
+		if end < 0 {
+			return len(source)
+		}
+		return len(strings.TrimSuffix(source[:end], "\r"))
+	case strings.HasPrefix(source, "`"):
+		return strings.IndexByte(source[1:], '`') + 2
+	}
+	return len(lit)
+}
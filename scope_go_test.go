@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// regionTexts returns the text each span covers.
+func regionTexts(content string, spans []span) []string {
+	var texts []string
+	for _, s := range spans {
+		texts = append(texts, content[s.start:s.end])
+	}
+	return texts
+}
+
+func TestGoRegionsComments(t *testing.T) {
+	content := "package p // trailing\n/* block\nspanning lines */ var x = 1\n//go:generate stringer\n"
+
+	comments, literals, err := goRegions(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"// trailing", "/* block\nspanning lines */", "//go:generate stringer"}
+	if got := regionTexts(content, comments); !reflect.DeepEqual(got, want) {
+		t.Errorf("comments = %q, want %q", got, want)
+	}
+	if len(literals) != 0 {
+		t.Errorf("literals = %q, want none", regionTexts(content, literals))
+	}
+}
+
+func TestGoRegionsLiteralsExcludeQuotes(t *testing.T) {
+	content := "package p\nvar s = \"a \\\"quoted\\\" // not a comment\" + `raw\nstring` + string('x')\n"
+
+	comments, literals, err := goRegions(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`a \"quoted\" // not a comment`, "raw\nstring", "x"}
+	if got := regionTexts(content, literals); !reflect.DeepEqual(got, want) {
+		t.Errorf("literals = %q, want %q", got, want)
+	}
+	if len(comments) != 0 {
+		t.Errorf("a // inside a string was taken for a comment: %q", regionTexts(content, comments))
+	}
+}
+
+func TestGoRegionsKeepCarriageReturns(t *testing.T) {
+	// go/scanner strips \r from comments and raw strings, the spans must still cover the source bytes.
+	content := "package p // note\r\nvar s = `a\r\nb` /* c\r\nd */\r\n"
+
+	comments, literals, err := goRegions(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := regionTexts(content, comments), []string{"// note", "/* c\r\nd */"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("comments = %q, want %q", got, want)
+	}
+	if got, want := regionTexts(content, literals), []string{"a\r\nb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("literals = %q, want %q", got, want)
+	}
+}
+
+func TestGoRegionsRejectUnterminatedStrings(t *testing.T) {
+	if _, _, err := goRegions("package p\nvar s = \"open\n"); err == nil {
+		t.Error("expected an error for an unterminated string")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is what a replacement template can refer to for each match, e.g. {{.File}} or {{index .Groups 1}}.
+type templateData struct {
+	Match  string            // The matched text.
+	Groups []string          // Capture groups, with Groups[0] being the whole match.
+	Named  map[string]string // Named capture groups.
+	Path   string            // Path of the file, or directory, the match lives in.
+	File   string            // Base name of Path.
+	Dir    string            // Directory of Path.
+	Ext    string            // Extension of File, including the dot.
+	Line   int               // Line number of the match, 0 when renaming.
+	Index  int               // 1 for the rule's first match in this file, 2 for the second and so on.
+}
+
+// templateFuncs lists the helpers available to replacement templates.
+func (ns *NameShifter) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"title":   func(s string) string { return recaseWords(s, shapeTitle) },
+		"sha":     shortSHA,
+		"counter": ns.nextCounter,
+	}
+	for name, style := range map[string]string{
+		"camel":     "camelCase",
+		"pascal":    "PascalCase",
+		"snake":     "snake_case",
+		"screaming": "SCREAMING_SNAKE_CASE",
+		"kebab":     "kebab-case",
+	} {
+		funcs[name] = renderStyle(style)
+	}
+	return funcs
+}
+
+// renderStyle returns a template function spelling its argument in the named identifier convention.
+func renderStyle(name string) func(string) string {
+	for _, style := range identifierStyles {
+		if style.name == name {
+			return func(s string) string {
+				words := splitWords(s)
+				if len(words) == 0 {
+					return s
+				}
+				return style.render(words)
+			}
+		}
+	}
+	panic("unknown identifier style " + name)
+}
+
+// shortSHA returns the first 7 hex digits of the SHA-1 of s, like a short git hash.
+func shortSHA(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:7]
+}
+
+// nextCounter hands out 1, 2, 3... across the whole run. Passing a name keeps a separate sequence per name.
+func (ns *NameShifter) nextCounter(names ...string) int {
+	name := strings.Join(names, "\x00")
+	ns.counterMu.Lock()
+	defer ns.counterMu.Unlock()
+	if ns.counters == nil {
+		ns.counters = make(map[string]int)
+	}
+	ns.counters[name]++
+	return ns.counters[name]
+}
+
+// executeTemplate renders the rule's replacement template for a single match.
+func (ns *NameShifter) executeTemplate(original string, rule *Rule, match []int, state *fileState) (string, error) {
+	data := templateData{
+		Match: original[match[0]:match[1]],
+		Named: make(map[string]string),
+		Path:  state.path,
+		File:  filepath.Base(state.path),
+		Dir:   filepath.Dir(state.path),
+		Ext:   filepath.Ext(state.path),
+		Line:  state.lineOf(original, match[0]),
+		Index: state.tally[rule] + 1,
+	}
+
+	var names []string
+	if regex, err := ns.compilePattern(rule); err == nil && rule.Regex {
+		names = regex.SubexpNames()
+	}
+	for i := 0; i+1 < len(match); i += 2 {
+		group := ""
+		if match[i] >= 0 {
+			group = original[match[i]:match[i+1]]
+		}
+		data.Groups = append(data.Groups, group)
+		if i/2 < len(names) && names[i/2] != "" {
+			data.Named[names[i/2]] = group
+		}
+	}
+
+	var b strings.Builder
+	if err := rule.template.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
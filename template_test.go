@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// templateRule returns a validated template rule, so its replacement is parsed and ready to run.
+func templateRule(t *testing.T, ns *NameShifter, search, replacement string) *Rule {
+	t.Helper()
+	rule := ns.newRule(search, replacement)
+	if err := ns.validateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func TestTemplateContext(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, Template: true}, NewAppContext())
+	rule := templateRule(t, ns, `TODO\((?P<who>\w+)\)`, "TODO({{.Named.who}}, {{.File}}:{{.Line}} #{{.Index}})")
+
+	got := shiftFile(t, ns, "store.go", "// TODO(ann)\nx := 1\n// TODO(bob) TODO(cy)\n", rule)
+	want := "// TODO(ann, store.go:1 #1)\nx := 1\n// TODO(bob, store.go:3 #2) TODO(cy, store.go:3 #3)\n"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestTemplateLineInMultilineMode(t *testing.T) {
+	// A whole-file match still gets the line it starts on.
+	ns := NewNameShifter(&Config{CaseMatching: true, Template: true, Multiline: true}, NewAppContext())
+	rule := templateRule(t, ns, "marker", "{{.Match}}@{{.Line}}")
+
+	if got := shiftFile(t, ns, "a.md", "one\ntwo marker\n\nmarker\n", rule); got != "one\ntwo marker@2\n\nmarker@4\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTemplateFunctions(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Regex: true, Template: true}, NewAppContext())
+
+	tests := []struct {
+		replacement, want string
+	}{
+		{`{{snake (index .Groups 1)}}`, "order_item"},
+		{`{{screaming .Match}}`, "NEW_ORDER_ITEM"},
+		{`{{kebab (index .Groups 1)}}`, "order-item"},
+		{`{{camel "Order Item"}}`, "orderItem"},
+		{`{{upper .Ext}}{{lower "MD"}}`, ".MDmd"},
+		{`{{title "hello big world"}}`, "Hello Big World"},
+		{`{{sha "nsh"}}`, shortSHA("nsh")},
+	}
+	for _, tt := range tests {
+		rule := templateRule(t, ns, `new(\w+)`, tt.replacement)
+		state := newFileState("docs/notes.MD")
+		if got := ns.replaceString("newOrderItem", rule, state); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.replacement, got, tt.want)
+		}
+	}
+	if len(shortSHA("nsh")) != 7 {
+		t.Errorf("sha should be 7 hex digits, got %q", shortSHA("nsh"))
+	}
+}
+
+func TestTemplateCounters(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Template: true}, NewAppContext())
+	rule := templateRule(t, ns, "?", `{{counter}}{{counter "b"}}`)
+
+	got := ns.replaceString("? ? ?", rule, newFileState("a"))
+	got += " " + ns.replaceString("?", rule, newFileState("b"))
+	if want := "11 22 33 44"; got != want {
+		t.Errorf("got %q, want %q: counters run across files, one sequence per name", got, want)
+	}
+}
+
+func TestTemplateParseErrorFailsValidation(t *testing.T) {
+	ns := NewNameShifter(&Config{Template: true}, NewAppContext())
+
+	err := ns.validateRule(ns.newRule("a", "{{.Match"))
+	if err == nil || !strings.Contains(err.Error(), "invalid replacement template") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestTemplateExecutionErrorKeepsMatch(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Template: true}, NewAppContext())
+	rule := templateRule(t, ns, "id", "{{index .Groups 5}}")
+
+	if got := ns.replaceString("an id", rule, newFileState("a.go")); got != "an id" {
+		t.Errorf("got %q, a failed template should leave the match alone", got)
+	}
+	if ns.Context.errorsCount != 1 {
+		t.Errorf("errors = %d, want 1", ns.Context.errorsCount)
+	}
+}
+
+func TestTemplateExecutionErrorIsNotCounted(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true, Template: true}, NewAppContext())
+	rule := templateRule(t, ns, "id", `{{if eq .Line 1}}{{index .Groups 5}}{{end}}id{{.Index}}`)
+
+	state := newFileState("a.go")
+	state.line = 1
+	if got := ns.replaceString("id\nid id", rule, state); got != "id\nid1 id2" {
+		t.Errorf("got %q, the failed match shouldn't take an index", got)
+	}
+	if state.tally[rule] != 2 {
+		t.Errorf("tally = %d, want the two rendered matches only", state.tally[rule])
+	}
+}
+
+func TestTemplateOptionInRulesFile(t *testing.T) {
+	ns := NewNameShifter(&Config{CaseMatching: true}, NewAppContext())
+
+	rules, err := ns.loadRules(writeRulesFile(t, "rules.txt", "id\t{{upper .Match}}\ttemplate\nid\t{{upper .Match}}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rules[0].Template || rules[1].Template {
+		t.Errorf("template options = %v, %v; want only the first rule to be a template", rules[0].Template, rules[1].Template)
+	}
+}